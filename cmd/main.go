@@ -18,20 +18,65 @@ func main() {
 	utils.InitLogger()
 
 	cfg := config.LoadConfig()
+	// Load JWT signing keys
+	if err := utils.InitJWTKeys(cfg.JWT); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+	// Load keys for encrypted columns
+	if err := utils.InitDataKeys(cfg.Encryption); err != nil {
+		log.Fatal("Failed to load encryption keys:", err)
+	}
+	// Password hashing cost
+	if err := utils.InitPasswordHasher(cfg.Password); err != nil {
+		log.Fatal("Invalid password hashing cost:", err)
+	}
 	// Init DB
 	db := provider.InitDB(cfg.Database)
 	// Init sqlc Queries
 	queries := repository.New(db)
 
+	// Init SMS sender
+	smsSender := provider.NewSMSSender(cfg.SMS)
+
+	// Init mailer and signed links
+	mailer := provider.NewMailer(cfg.Mail)
+	utils.InitLinkSigner(cfg.App)
+
+	// Init session store
+	utils.InitTokenStore(queries)
+
 	// Init Handlers
-	authHandler := routes.NewAuthHandler(queries)
-	profileHandler := routes.NewProfileHandler(queries)
+	authHandler := routes.NewAuthHandler(queries, mailer)
+	profileHandler := routes.NewProfileHandler(queries, mailer, smsSender)
 	fileHandler := routes.NewFileHandler(queries)
-	productHandler := routes.NewProductHandler(queries)
+	productHandler := routes.NewProductHandler(queries, db)
+	variantHandler := routes.NewVariantHandler(queries)
 	purchaseHandler := routes.NewPurchaseHandler(queries, db)
+	tokenHandler := routes.NewTokenHandler(queries, db)
+	sessionHandler := routes.NewSessionHandler(queries)
+	otpHandler := routes.NewOTPHandler(queries, smsSender)
+	emailHandler := routes.NewEmailHandler(queries, mailer)
+	passwordHandler := routes.NewPasswordHandler(queries, db, mailer, smsSender)
+	lockoutHandler := routes.NewLockoutHandler(queries)
+	roleHandler := routes.NewRoleHandler(queries)
+	apiKeyHandler := routes.NewAPIKeyHandler(queries)
+	totpHandler := routes.NewTOTPHandler(queries, db)
+	accountHandler := routes.NewAccountHandler(queries, db)
+	bankAccountHandler := routes.NewBankAccountHandler(queries, db)
+	bankHandler := routes.NewBankHandler(queries)
+	sellerHandler := routes.NewSellerHandler(queries)
+	storeHandler := routes.NewStoreHandler(queries, db, mailer, smsSender)
+	categoryHandler := routes.NewCategoryHandler(queries)
+	oidcHandler := routes.NewOIDCHandler(queries, db, provider.NewOIDCProviders(cfg.OIDC, cfg.App.BaseURL))
 
 	// Start token cleanup routine
 	utils.GlobalTokenStore.StartCleanupRoutine()
+	tokenHandler.StartCleanupRoutine()
+	otpHandler.StartCleanupRoutine()
+	passwordHandler.StartCleanupRoutine()
+	lockoutHandler.StartCleanupRoutine()
+	oidcHandler.StartCleanupRoutine()
+	totpHandler.StartCleanupRoutine()
 
 	// Setup Gin
 	r := gin.Default()
@@ -44,31 +89,120 @@ func main() {
 		c.String(200, "OK")
 	})
 
+	// Public keys for services that verify our access tokens
+	r.GET("/.well-known/jwks.json", tokenHandler.JWKS)
+
 	// V1 API Routes according to requirement
 	v1 := r.Group("/v1")
 	{
 		// Public routes (no authentication required)
 		v1.POST("/login/email", authHandler.LoginEmail)
 		v1.POST("/login/phone", authHandler.LoginPhone)
+		v1.POST("/login/2fa", totpHandler.Login2FA)
+		v1.GET("/login/oidc/:provider", oidcHandler.StartLogin)
+		v1.GET("/login/oidc/:provider/callback", oidcHandler.Callback)
 		v1.POST("/register/email", authHandler.RegisterEmail)
 		v1.POST("/register/phone", authHandler.RegisterPhone)
+		v1.POST("/token/refresh", tokenHandler.RefreshToken)
+		v1.GET("/email/verify", emailHandler.VerifyEmail)
+		v1.POST("/password/forgot", passwordHandler.ForgotPassword)
+		v1.POST("/password/reset", passwordHandler.ResetPassword)
 		v1.POST("/file", fileHandler.UploadFile)
 		v1.GET("/product", productHandler.GetProducts)
+		v1.GET("/product/:productId/variants", variantHandler.ListVariants)
+		v1.GET("/category", categoryHandler.GetCategoryTree)
+		v1.GET("/banks", bankHandler.ListBanks)
+		v1.GET("/seller/:sellerId", sellerHandler.GetSeller)
+		v1.GET("/seller/:sellerId/products", sellerHandler.GetSellerProducts)
 		v1.POST("/purchase", purchaseHandler.CreatePurchase)
 		v1.POST("/purchase/:purchaseId", purchaseHandler.ConfirmPayment)
 
 		// Protected routes (require authentication)
 		protected := v1.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(queries))
 		{
+			// Session routes
+			protected.POST("/logout", tokenHandler.Logout)
+			protected.POST("/logout/all", tokenHandler.LogoutAll)
+			protected.GET("/user/sessions", sessionHandler.ListSessions)
+			protected.DELETE("/user/sessions/:id", sessionHandler.RevokeSession)
+
 			// User profile routes
 			protected.GET("/user", profileHandler.GetProfile)
 			protected.PUT("/user", profileHandler.UpdateProfile)
+			protected.PUT("/user/password", passwordHandler.ChangePassword)
 			protected.POST("/user/link/phone", profileHandler.LinkPhone)
 			protected.POST("/user/link/email", profileHandler.LinkEmail)
-			protected.POST("/product", productHandler.CreateProduct)
-			protected.PUT("/product/:productId", productHandler.UpdateProduct)
-			protected.DELETE("/product/:productId", productHandler.DeleteProduct)
+			protected.GET("/user/export", accountHandler.ExportData)
+			protected.DELETE("/user", accountHandler.DeleteAccount)
+			protected.POST("/otp/phone", otpHandler.SendPhoneOTP)
+			protected.POST("/otp/phone/verify", otpHandler.VerifyPhoneOTP)
+			protected.POST("/email/verify/resend", emailHandler.ResendVerification)
+
+			// Payout account routes
+			protected.POST("/user/bank-accounts", bankAccountHandler.CreateBankAccount)
+			protected.GET("/user/bank-accounts", bankAccountHandler.ListBankAccounts)
+			protected.PUT("/user/bank-accounts/:id", bankAccountHandler.UpdateBankAccount)
+			protected.DELETE("/user/bank-accounts/:id", bankAccountHandler.DeleteBankAccount)
+
+			// Two-factor authentication routes
+			protected.POST("/user/2fa/totp", totpHandler.EnrollTOTP)
+			protected.POST("/user/2fa/totp/verify", totpHandler.ConfirmTOTP)
+			protected.DELETE("/user/2fa/totp", totpHandler.DisableTOTP)
+
+			// API key routes, keys can not manage keys
+			protected.POST("/user/api-keys", apiKeyHandler.CreateAPIKey)
+			protected.GET("/user/api-keys", apiKeyHandler.ListAPIKeys)
+			protected.DELETE("/user/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+
+			// Store staff routes, owners invite staff with a subset of the store permissions
+			protected.GET("/user/stores", storeHandler.ListStores)
+			protected.POST("/store/invitations/accept", storeHandler.AcceptInvitation)
+			protected.GET("/store/:storeId/staff", storeHandler.ListStaff)
+			protected.PUT("/store/:storeId/staff/:userId", storeHandler.UpdateStaff)
+			protected.DELETE("/store/:storeId/staff/:userId", storeHandler.RemoveStaff)
+			protected.POST("/store/:storeId/invitations", storeHandler.InviteStaff)
+			protected.DELETE("/store/:storeId/invitations/:invitationId", storeHandler.RevokeInvitation)
+			protected.POST("/store/:storeId/orders/:purchaseId/confirm", storeHandler.ConfirmOrderPayment)
+		}
+
+		// Store order routes (user token or API key with the orders:read scope)
+		ordersRead := v1.Group("/")
+		ordersRead.Use(middleware.AuthMiddleware(queries, utils.ScopeOrdersRead))
+		{
+			ordersRead.GET("/store/:storeId/orders", storeHandler.ListOrders)
+		}
+
+		// Own product routes (user token or API key with the products:read scope)
+		productRead := v1.Group("/")
+		productRead.Use(middleware.AuthMiddleware(queries, utils.ScopeProductsRead))
+		{
+			productRead.GET("/user/products", productHandler.GetOwnProducts)
+		}
+
+		// Product routes (user token or API key with the products:write scope)
+		productWrite := v1.Group("/")
+		productWrite.Use(middleware.AuthMiddleware(queries, utils.ScopeProductsWrite))
+		{
+			productWrite.POST("/product", productHandler.CreateProduct)
+			productWrite.PUT("/product/:productId", productHandler.UpdateProduct)
+			productWrite.DELETE("/product/:productId", productHandler.DeleteProduct)
+			productWrite.POST("/product/:productId/variants", variantHandler.CreateVariant)
+			productWrite.PUT("/product/:productId/variants/:variantId", variantHandler.UpdateVariant)
+			productWrite.DELETE("/product/:productId/variants/:variantId", variantHandler.DeleteVariant)
+		}
+
+		// Admin routes (require authentication and the admin role)
+		admin := v1.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(queries), middleware.RequireRole(utils.RoleAdmin))
+		{
+			admin.GET("/lockouts", middleware.RequirePermission(utils.PermissionManageLockouts), lockoutHandler.ListLockouts)
+			admin.DELETE("/lockouts", middleware.RequirePermission(utils.PermissionManageLockouts), lockoutHandler.ClearLockout)
+			admin.PUT("/users/:userId/role", middleware.RequirePermission(utils.PermissionManageUsers), roleHandler.SetUserRole)
+			admin.GET("/categories", middleware.RequirePermission(utils.PermissionManageCategories), categoryHandler.ListCategories)
+			admin.POST("/categories", middleware.RequirePermission(utils.PermissionManageCategories), categoryHandler.CreateCategory)
+			admin.PUT("/categories/:categoryId", middleware.RequirePermission(utils.PermissionManageCategories), categoryHandler.UpdateCategory)
+			admin.DELETE("/categories/:categoryId", middleware.RequirePermission(utils.PermissionManageCategories), categoryHandler.DeleteCategory)
 		}
 	}
 
@@ -0,0 +1,135 @@
+// Command reencrypt moves every encrypted column to the active data key and refreshes
+// the blind indexes. Run it after adding a key to ENCRYPTION_KEYS and making it active,
+// the retired key can be removed from the configuration once it finished. Plaintext
+// values written before encryption was introduced are encrypted on the way.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"tutuplapak-go/config"
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+)
+
+// Rows are read in batches ordered by id, the command can be stopped and run again
+const batchSize = 500
+
+func main() {
+	utils.InitLogger()
+
+	cfg := config.LoadConfig()
+	if err := utils.InitDataKeys(cfg.Encryption); err != nil {
+		log.Fatal("Failed to load encryption keys:", err)
+	}
+	db := provider.InitDB(cfg.Database)
+	defer db.Close()
+	queries := repository.New(db)
+	ctx := context.Background()
+
+	updated, err := reencryptBankAccounts(ctx, queries)
+	if err != nil {
+		log.Fatal("Failed to re-encrypt bank accounts:", err)
+	}
+	log.Printf("Bank accounts: %d rows updated", updated)
+
+	updated, err = reencryptPurchases(ctx, queries)
+	if err != nil {
+		log.Fatal("Failed to re-encrypt purchases:", err)
+	}
+	log.Printf("Purchases: %d rows updated", updated)
+}
+
+func reencryptBankAccounts(ctx context.Context, queries *repository.Queries) (int, error) {
+	updated := 0
+	var lastID int32
+	for {
+		rows, err := queries.ListBankAccountsForReencryption(ctx, repository.ListBankAccountsForReencryptionParams{
+			ID:    lastID,
+			Limit: batchSize,
+		})
+		if err != nil {
+			return updated, err
+		}
+		for _, row := range rows {
+			lastID = row.ID
+			// Deleted accounts were emptied, there is nothing to protect
+			if row.BankAccountNumber == "" {
+				continue
+			}
+			number, err := utils.DataKeys.Decrypt(utils.FieldBankAccountNumber, row.BankAccountNumber)
+			if err != nil {
+				return updated, err
+			}
+			index := sql.NullString{String: utils.DataKeys.BlindIndex(utils.FieldBankAccountNumber, number), Valid: true}
+			if !utils.DataKeys.NeedsReencryption(row.BankAccountNumber) && row.BankAccountNumberBidx == index {
+				continue
+			}
+
+			sealed, err := utils.DataKeys.Encrypt(utils.FieldBankAccountNumber, number)
+			if err != nil {
+				return updated, err
+			}
+			err = queries.ReencryptBankAccountNumber(ctx, repository.ReencryptBankAccountNumberParams{
+				ID:                    row.ID,
+				BankAccountNumber:     sealed,
+				BankAccountNumberBidx: index,
+			})
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+		if len(rows) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+func reencryptPurchases(ctx context.Context, queries *repository.Queries) (int, error) {
+	updated := 0
+	var lastID int32
+	for {
+		rows, err := queries.ListPurchasesForReencryption(ctx, repository.ListPurchasesForReencryptionParams{
+			ID:    lastID,
+			Limit: batchSize,
+		})
+		if err != nil {
+			return updated, err
+		}
+		for _, row := range rows {
+			lastID = row.ID
+			if !row.SenderContactDetail.Valid || row.SenderContactDetail.String == "" {
+				continue
+			}
+			detail, err := utils.DataKeys.Decrypt(utils.FieldSenderContactDetail, row.SenderContactDetail.String)
+			if err != nil {
+				return updated, err
+			}
+			index := sql.NullString{String: utils.DataKeys.SenderContactIndex(row.SenderContactType.String, detail), Valid: true}
+			if !utils.DataKeys.NeedsReencryption(row.SenderContactDetail.String) && row.SenderContactBidx == index {
+				continue
+			}
+
+			sealed, err := utils.DataKeys.Encrypt(utils.FieldSenderContactDetail, detail)
+			if err != nil {
+				return updated, err
+			}
+			err = queries.ReencryptPurchaseContact(ctx, repository.ReencryptPurchaseContactParams{
+				ID:                  row.ID,
+				SenderContactDetail: sql.NullString{String: sealed, Valid: true},
+				SenderContactBidx:   index,
+			})
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+		if len(rows) < batchSize {
+			return updated, nil
+		}
+	}
+}
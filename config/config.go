@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -15,8 +17,89 @@ type DBConfig struct {
 	Name string
 }
 
+// JWTKeyConfig describes one signing key, KeyPath points to a PEM file
+// (private key, or public key for verify-only keys) or to a raw secret for HS256
+type JWTKeyConfig struct {
+	ID        string
+	Algorithm string
+	KeyPath   string
+}
+
+type JWTConfig struct {
+	Issuer      string
+	ActiveKeyID string
+	Keys        []JWTKeyConfig
+}
+
+// DataKeyConfig describes one key encryption key, KeyPath points to a file
+// with 32 random bytes, base64 encoded
+type DataKeyConfig struct {
+	ID      string
+	KeyPath string
+}
+
+// EncryptionConfig holds the keys for encrypted columns. Retired keys stay listed
+// until the re-encryption command moved every row to the active key.
+// BlindIndexKeyPath points to a separate key for the equality lookup indexes.
+type EncryptionConfig struct {
+	ActiveKeyID       string
+	Keys              []DataKeyConfig
+	BlindIndexKeyPath string
+}
+
+// PasswordConfig holds the argon2id cost, Memory is in KiB. Raising any of them
+// upgrades existing hashes on the next login. The values are kept as configured,
+// utils.InitPasswordHasher rejects those argon2id can not use.
+type PasswordConfig struct {
+	Memory      int64
+	Iterations  int64
+	Parallelism int64
+}
+
+// SMSConfig selects the SMS sender, "log" writes messages to the application log
+// and "file" appends them to FilePath
+type SMSConfig struct {
+	Driver   string
+	FilePath string
+}
+
+// MailConfig selects the mailer, "smtp" delivers through an SMTP server,
+// "file" appends messages to FilePath and "memory" keeps them in memory
+type MailConfig struct {
+	Driver   string
+	From     string
+	SMTPHost string
+	SMTPPort string
+	SMTPUser string
+	SMTPPass string
+	FilePath string
+}
+
+// AppConfig holds settings for links sent to users, LinkSecret signs them
+type AppConfig struct {
+	BaseURL    string
+	LinkSecret string
+}
+
+// OIDCProviderConfig describes one OpenID Connect issuer users can sign in with.
+// Name is used in the login URL, e.g. /v1/login/oidc/google.
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
 type Config struct {
-	Database DBConfig
+	App        AppConfig
+	Database   DBConfig
+	JWT        JWTConfig
+	Encryption EncryptionConfig
+	Password   PasswordConfig
+	SMS        SMSConfig
+	Mail       MailConfig
+	OIDC       []OIDCProviderConfig
 }
 
 // LoadConfig loads from .env if present, else from system env
@@ -27,6 +110,10 @@ func LoadConfig() *Config {
 	}
 
 	cfg := &Config{
+		App: AppConfig{
+			BaseURL:    strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:8080"), "/"),
+			LinkSecret: getEnv("APP_LINK_SECRET", ""),
+		},
 		Database: DBConfig{
 			Host: getEnv("DB_HOST", ""),
 			Port: getEnv("DB_PORT", ""),
@@ -34,6 +121,35 @@ func LoadConfig() *Config {
 			Pass: getEnv("DB_PASS", ""),
 			Name: getEnv("DB_NAME", ""),
 		},
+		JWT: JWTConfig{
+			Issuer:      getEnv("JWT_ISSUER", "tutuplapak"),
+			ActiveKeyID: getEnv("JWT_ACTIVE_KID", ""),
+			Keys:        parseJWTKeys(getEnv("JWT_KEYS", "")),
+		},
+		Encryption: EncryptionConfig{
+			ActiveKeyID:       getEnv("ENCRYPTION_ACTIVE_KID", ""),
+			Keys:              parseDataKeys(getEnv("ENCRYPTION_KEYS", "")),
+			BlindIndexKeyPath: getEnv("BLIND_INDEX_KEY_PATH", ""),
+		},
+		Password: PasswordConfig{
+			Memory:      int64(getEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024)),
+			Iterations:  int64(getEnvInt("PASSWORD_ARGON2_ITERATIONS", 3)),
+			Parallelism: int64(getEnvInt("PASSWORD_ARGON2_PARALLELISM", 2)),
+		},
+		SMS: SMSConfig{
+			Driver:   getEnv("SMS_DRIVER", "log"),
+			FilePath: getEnv("SMS_FILE_PATH", "./tmp/sms.log"),
+		},
+		Mail: MailConfig{
+			Driver:   getEnv("MAIL_DRIVER", "file"),
+			From:     getEnv("MAIL_FROM", "TutupLapak <no-reply@tutuplapak.local>"),
+			SMTPHost: getEnv("SMTP_HOST", ""),
+			SMTPPort: getEnv("SMTP_PORT", "587"),
+			SMTPUser: getEnv("SMTP_USER", ""),
+			SMTPPass: getEnv("SMTP_PASS", ""),
+			FilePath: getEnv("MAIL_FILE_PATH", "./tmp/mail.log"),
+		},
+		OIDC: parseOIDCProviders(getEnv("OIDC_PROVIDERS", "")),
 	}
 
 	return cfg
@@ -46,3 +162,82 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// Helper function: positive integer env var with fallback
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Ignoring %s=%q, expected a positive integer", key, value)
+		return fallback
+	}
+	return n
+}
+
+// parseJWTKeys parses "kid:alg:path" entries separated by commas,
+// e.g. "2025-10:EdDSA:/keys/jwt-2025-10.pem,2025-04:RS256:/keys/jwt-2025-04.pem".
+// A malformed entry stops startup, skipping it could leave an ephemeral signing key.
+func parseJWTKeys(value string) []JWTKeyConfig {
+	var keys []JWTKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			log.Fatalf("Malformed JWT_KEYS entry %q, expected kid:alg:path", entry)
+		}
+		keys = append(keys, JWTKeyConfig{ID: parts[0], Algorithm: parts[1], KeyPath: parts[2]})
+	}
+	return keys
+}
+
+// parseDataKeys parses "kid:path" entries separated by commas,
+// e.g. "2025-10:/keys/data-2025-10.key,2025-04:/keys/data-2025-04.key".
+// A malformed entry stops startup like one in JWT_KEYS.
+func parseDataKeys(value string) []DataKeyConfig {
+	var keys []DataKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("Malformed ENCRYPTION_KEYS entry %q, expected kid:path", entry)
+		}
+		keys = append(keys, DataKeyConfig{ID: parts[0], KeyPath: parts[1]})
+	}
+	return keys
+}
+
+// parseOIDCProviders reads the providers named in OIDC_PROVIDERS, e.g. "google,mock".
+// Every provider is configured through OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET and optionally OIDC_<NAME>_SCOPES.
+func parseOIDCProviders(value string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    strings.TrimRight(getEnv(prefix+"ISSUER", ""), "/"),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.IssuerURL == "" || provider.ClientID == "" {
+			log.Printf("Ignoring OIDC provider %q, %sISSUER and %sCLIENT_ID are required", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
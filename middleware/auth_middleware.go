@@ -1,10 +1,14 @@
 package middleware
 
 import (
-	"fmt"
+	"database/sql"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
+	"tutuplapak-go/repository"
 	"tutuplapak-go/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,14 +16,25 @@ import (
 )
 
 type AppClaims struct {
-	UserID int32 `json:"user_id"`
+	UserID    int32  `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// AuthMiddleware validates Bearer tokens and sets user_id in context
-func AuthMiddleware() gin.HandlerFunc {
+// How often last_used_at is written for an API key in use
+const apiKeyTouchInterval = time.Minute
+
+// AuthMiddleware validates Bearer tokens and sets user_id and role in context.
+// Routes that pass scopes also accept an X-API-Key header, the key must have been
+// granted every one of them. Routes without scopes only accept user tokens.
+func AuthMiddleware(queries *repository.Queries, scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && c.GetHeader("X-API-Key") != "" {
+			authenticateAPIKey(c, queries, scopes)
+			return
+		}
 		if authHeader == "" {
 			utils.Logger.Error().Msg("Authorization header is empty")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -37,12 +52,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		claims := &AppClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte("secret"), nil
-		})
+		token, err := utils.ParseJWTToken(tokenStr, claims)
 
 		if err != nil || !token.Valid {
 			utils.Logger.Error().Err(err).Msg("Unauthorized: Invalid token")
@@ -50,8 +60,80 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Access tokens must carry a jti, a session and an expiry so they can be revoked
+		if claims.ID == "" || claims.SessionID == "" || claims.ExpiresAt == nil {
+			utils.Logger.Error().Msg("Unauthorized: Token without jti, sid or exp")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// Reject tokens that were revoked by logout or refresh token reuse
+		revoked, err := queries.IsAccessTokenRevoked(c, claims.ID)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to check token revocation")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		if revoked {
+			utils.Logger.Error().Str("jti", claims.ID).Msg("Unauthorized: Token revoked")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// The session must still be active, this is how signed out devices lose access
+		sessionUserID, exists := utils.GlobalTokenStore.GetUserID(c, claims.SessionID)
+		if !exists || sessionUserID != claims.UserID {
+			utils.Logger.Error().Msg("Unauthorized: Session not found")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
 		// Set user ID in context for use in handlers
 		c.Set("user_id", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_jti", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
 		c.Next()
 	}
 }
+
+func authenticateAPIKey(c *gin.Context, queries *repository.Queries, scopes []string) {
+	if len(scopes) == 0 {
+		utils.Logger.Error().Msg("Unauthorized: API keys are not accepted on this route")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	apiKey, err := queries.GetActiveAPIKeyByHash(c, utils.HashToken(c.GetHeader("X-API-Key")))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logger.Error().Err(err).Msg("Failed to get API key")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		utils.Logger.Error().Msg("Unauthorized: Invalid API key")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(apiKey.Scopes, scope) {
+			utils.Logger.Error().Str("scope", scope).Msg("Forbidden: API key is missing a scope")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+	}
+
+	// Throttle writes, last used only needs to be accurate to the minute
+	if !apiKey.LastUsedAt.Valid || time.Since(apiKey.LastUsedAt.Time) > apiKeyTouchInterval {
+		if err := queries.TouchAPIKey(c, apiKey.ID); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to touch API key")
+		}
+	}
+
+	// No role is set, API keys never pass role or permission checks
+	c.Set("user_id", apiKey.UserID)
+	c.Set("api_key_id", apiKey.ID)
+	c.Next()
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets requests through whose token carries one of the roles.
+// It must run after AuthMiddleware, which puts the role into the context.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := roleFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if !slices.Contains(roles, role) {
+			utils.Logger.Error().Str("role", role).Msg("Forbidden: Role not allowed")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission only lets requests through whose role grants the permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := roleFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if !utils.HasPermission(role, permission) {
+			utils.Logger.Error().Str("role", role).Str("permission", permission).Msg("Forbidden: Missing permission")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func roleFromContext(c *gin.Context) (string, bool) {
+	value, exists := c.Get("role")
+	if !exists {
+		return "", false
+	}
+	role, ok := value.(string)
+	return role, ok
+}
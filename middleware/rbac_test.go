@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+// serve runs guard behind a stub of AuthMiddleware that sets role when it is not nil
+func serve(guard gin.HandlerFunc, role interface{}) int {
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		if role != nil {
+			c.Set("role", role)
+		}
+		c.Next()
+	}, guard, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		role  interface{}
+		want  int
+	}{
+		{"allowed role", []string{utils.RoleAdmin}, utils.RoleAdmin, http.StatusOK},
+		{"one of several roles", []string{utils.RoleSeller, utils.RoleAdmin}, utils.RoleSeller, http.StatusOK},
+		{"other role", []string{utils.RoleAdmin}, utils.RoleBuyer, http.StatusForbidden},
+		{"empty role from an old token", []string{utils.RoleBuyer}, "", http.StatusForbidden},
+		{"no role in context", []string{utils.RoleAdmin}, nil, http.StatusUnauthorized},
+		{"role of the wrong type", []string{utils.RoleAdmin}, 1, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(RequireRole(tt.roles...), tt.role); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission string
+		role       interface{}
+		want       int
+	}{
+		{"admin manages users", utils.PermissionManageUsers, utils.RoleAdmin, http.StatusOK},
+		{"admin manages lockouts", utils.PermissionManageLockouts, utils.RoleAdmin, http.StatusOK},
+		{"seller can not manage users", utils.PermissionManageUsers, utils.RoleSeller, http.StatusForbidden},
+		{"buyer can not manage lockouts", utils.PermissionManageLockouts, utils.RoleBuyer, http.StatusForbidden},
+		{"unknown role", utils.PermissionManageUsers, "superuser", http.StatusForbidden},
+		{"unknown permission", "unknown:permission", utils.RoleAdmin, http.StatusForbidden},
+		{"no role in context", utils.PermissionManageUsers, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(RequirePermission(tt.permission), tt.role); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN phone_verified_at TIMESTAMPTZ;
+
+-- Table: phone_otps
+-- One time codes sent by SMS, only the bcrypt hash of the code is stored.
+CREATE TABLE phone_otps (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(20) NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_phone_otps_phone_purpose ON phone_otps (phone, purpose, created_at);
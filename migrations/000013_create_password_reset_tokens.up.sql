@@ -0,0 +1,13 @@
+-- Table: password_reset_tokens
+-- Single use tokens mailed to reset a forgotten password, only the SHA-256 hash is stored.
+-- Resets by phone use phone_otps with the reset_password purpose instead.
+CREATE TABLE password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id, created_at);
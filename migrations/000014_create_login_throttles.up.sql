@@ -0,0 +1,11 @@
+-- Table: login_throttles
+-- Failed login counters shared by every API replica. The key is either
+-- "account:<email or phone>" or "ip:<client ip>".
+CREATE TABLE login_throttles (
+    key VARCHAR(300) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_throttles_last_failure_at ON login_throttles (last_failure_at);
@@ -0,0 +1,9 @@
+-- Every account starts as a buyer and becomes a seller with its first product.
+-- Admins are only granted by another admin, or for the first one manually:
+--   UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'buyer'
+        CONSTRAINT users_role_check CHECK (role IN ('buyer', 'seller', 'admin'));
+
+UPDATE users SET role = 'seller'
+WHERE id IN (SELECT DISTINCT user_id FROM products WHERE user_id IS NOT NULL);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS totp_recovery_codes;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS totp_secret,
+    DROP COLUMN IF EXISTS totp_enabled_at,
+    DROP COLUMN IF EXISTS totp_last_step;
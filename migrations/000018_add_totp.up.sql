@@ -0,0 +1,18 @@
+-- TOTP two factor authentication. totp_secret is set on enrollment and only takes
+-- effect once totp_enabled_at is set, totp_last_step stops a code from being replayed.
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64),
+    ADD COLUMN totp_enabled_at TIMESTAMPTZ,
+    ADD COLUMN totp_last_step BIGINT;
+
+-- Table: totp_recovery_codes
+-- Single use codes for when the authenticator is lost, only the SHA-256 hash is stored.
+CREATE TABLE totp_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_totp_recovery_codes_user_id ON totp_recovery_codes (user_id);
@@ -0,0 +1,29 @@
+ALTER TABLE purchase_item DROP CONSTRAINT IF EXISTS purchase_item_product_id_fkey;
+ALTER TABLE purchase_item
+    ADD CONSTRAINT purchase_item_product_id_fkey
+        FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_user_id_fkey;
+ALTER TABLE products
+    ADD CONSTRAINT products_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Deactivated products may share their SKU with a live product or with each other,
+-- they are renamed apart so the plain constraint can be restored
+UPDATE products p
+SET sku = p.sku || '-deleted-' || p.product_id
+WHERE NOT p.is_active
+  AND EXISTS (
+      SELECT 1 FROM products o
+      WHERE o.user_id = p.user_id AND o.sku = p.sku AND o.product_id <> p.product_id
+  );
+DROP INDEX IF EXISTS unique_sku_per_user;
+ALTER TABLE products ADD CONSTRAINT unique_sku_per_user UNIQUE (user_id, sku);
+ALTER TABLE products DROP COLUMN IF EXISTS is_active;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_or_phone_check;
+-- Anonymized accounts can not get their email or phone back, the check only covers new rows
+ALTER TABLE users
+    ADD CONSTRAINT users_email_or_phone_check
+        CHECK ((email IS NOT NULL AND email != '') OR (phone IS NOT NULL AND phone != '')) NOT VALID;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,26 @@
+-- Deleted accounts are anonymized instead of removed, so the purchase history of
+-- their products stays intact. A deleted account keeps neither email nor phone.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_or_phone_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_email_or_phone_check
+        CHECK (deleted_at IS NOT NULL OR (email IS NOT NULL AND email != '') OR (phone IS NOT NULL AND phone != ''));
+
+-- Products are deactivated instead of deleted once they may have been bought
+ALTER TABLE products ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- A deactivated product no longer blocks its SKU
+ALTER TABLE products DROP CONSTRAINT IF EXISTS unique_sku_per_user;
+CREATE UNIQUE INDEX unique_sku_per_user ON products (user_id, sku) WHERE is_active;
+
+-- Removing a user or product must never take purchase records with it
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_user_id_fkey;
+ALTER TABLE products
+    ADD CONSTRAINT products_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE purchase_item DROP CONSTRAINT IF EXISTS purchase_item_product_id_fkey;
+ALTER TABLE purchase_item
+    ADD CONSTRAINT purchase_item_product_id_fkey
+        FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE RESTRICT;
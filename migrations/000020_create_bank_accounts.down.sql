@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS purchase_payment_accounts;
+
+ALTER TABLE users
+    ADD COLUMN bank_account_name VARCHAR(255),
+    ADD COLUMN bank_account_holder VARCHAR(255),
+    ADD COLUMN bank_account_number VARCHAR(50);
+
+UPDATE users u
+SET
+    bank_account_name = b.bank_account_name,
+    bank_account_holder = b.bank_account_holder,
+    bank_account_number = b.bank_account_number
+FROM bank_accounts b
+WHERE b.user_id = u.id AND b.is_default AND b.deleted_at IS NULL;
+
+DROP TABLE IF EXISTS bank_accounts;
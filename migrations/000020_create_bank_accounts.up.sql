@@ -0,0 +1,35 @@
+-- A seller can receive payments on several accounts, one of them is the default.
+-- Accounts are only soft deleted, purchases keep pointing at the accounts they showed.
+CREATE TABLE bank_accounts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    bank_account_name VARCHAR(255) NOT NULL,
+    bank_account_holder VARCHAR(255) NOT NULL,
+    bank_account_number VARCHAR(50) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_bank_accounts_user_id ON bank_accounts (user_id) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX uq_bank_accounts_default ON bank_accounts (user_id) WHERE is_default AND deleted_at IS NULL;
+
+-- The single account on the profile becomes the default account
+INSERT INTO bank_accounts (user_id, bank_account_name, bank_account_holder, bank_account_number, is_default)
+SELECT id, bank_account_name, bank_account_holder, bank_account_number, TRUE
+FROM users
+WHERE bank_account_number IS NOT NULL AND bank_account_number != '';
+
+ALTER TABLE users
+    DROP COLUMN bank_account_name,
+    DROP COLUMN bank_account_holder,
+    DROP COLUMN bank_account_number;
+
+-- The accounts a buyer was shown to pay a seller
+CREATE TABLE purchase_payment_accounts (
+    purchase_id INTEGER NOT NULL REFERENCES purchases(id) ON DELETE CASCADE,
+    bank_account_id INTEGER NOT NULL REFERENCES bank_accounts(id) ON DELETE RESTRICT,
+    PRIMARY KEY (purchase_id, bank_account_id)
+);
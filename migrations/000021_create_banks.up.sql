@@ -0,0 +1,39 @@
+-- Registry of the banks and e-wallets sellers can receive payments on, with the
+-- shape of their account numbers. Numbers are validated without spaces or dashes.
+CREATE TABLE banks (
+    code VARCHAR(16) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(16) NOT NULL DEFAULT 'bank'
+        CONSTRAINT banks_kind_check CHECK (kind IN ('bank', 'ewallet')),
+    account_number_min_length INTEGER NOT NULL,
+    account_number_max_length INTEGER NOT NULL,
+    account_number_pattern VARCHAR(100) NOT NULL DEFAULT '^[0-9]+$',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT banks_account_number_length_check
+        CHECK (account_number_min_length > 0 AND account_number_min_length <= account_number_max_length)
+);
+
+INSERT INTO banks (code, name, kind, account_number_min_length, account_number_max_length, account_number_pattern) VALUES
+    ('BCA', 'Bank Central Asia', 'bank', 10, 10, '^[0-9]+$'),
+    ('BRI', 'Bank Rakyat Indonesia', 'bank', 15, 15, '^[0-9]+$'),
+    ('BNI', 'Bank Negara Indonesia', 'bank', 10, 10, '^[0-9]+$'),
+    ('MANDIRI', 'Bank Mandiri', 'bank', 13, 13, '^[0-9]+$'),
+    ('BSI', 'Bank Syariah Indonesia', 'bank', 10, 10, '^[0-9]+$'),
+    ('CIMB', 'CIMB Niaga', 'bank', 12, 14, '^[0-9]+$'),
+    ('PERMATA', 'Bank Permata', 'bank', 10, 10, '^[0-9]+$'),
+    ('DANAMON', 'Bank Danamon', 'bank', 10, 12, '^[0-9]+$'),
+    ('BTN', 'Bank Tabungan Negara', 'bank', 16, 16, '^[0-9]+$'),
+    ('JAGO', 'Bank Jago', 'bank', 12, 12, '^[0-9]+$'),
+    ('GOPAY', 'GoPay', 'ewallet', 10, 13, '^08[0-9]+$'),
+    ('OVO', 'OVO', 'ewallet', 10, 13, '^08[0-9]+$'),
+    ('DANA', 'DANA', 'ewallet', 10, 13, '^08[0-9]+$'),
+    ('SHOPEEPAY', 'ShopeePay', 'ewallet', 10, 13, '^08[0-9]+$')
+ON CONFLICT DO NOTHING;
+
+-- Existing accounts keep working, those whose name matches a bank are linked to it
+ALTER TABLE bank_accounts ADD COLUMN bank_code VARCHAR(16) REFERENCES banks(code);
+
+UPDATE bank_accounts b
+SET bank_code = k.code
+FROM banks k
+WHERE UPPER(TRIM(b.bank_account_name)) IN (k.code, UPPER(k.name));
@@ -0,0 +1,2 @@
+-- Shown on the public seller storefront, sellers without one are shown by id only
+ALTER TABLE users ADD COLUMN store_name VARCHAR(64);
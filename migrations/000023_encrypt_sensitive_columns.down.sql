@@ -0,0 +1,5 @@
+-- Encrypted values do not fit the old column lengths, the columns stay TEXT
+DROP INDEX IF EXISTS idx_purchases_sender_contact_bidx;
+DROP INDEX IF EXISTS idx_bank_accounts_number_bidx;
+ALTER TABLE purchases DROP COLUMN IF EXISTS sender_contact_bidx;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS bank_account_number_bidx;
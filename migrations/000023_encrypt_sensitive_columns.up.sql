@@ -0,0 +1,12 @@
+-- Bank account numbers and buyer contact details are encrypted by the application
+-- (AES-GCM envelopes, see utils/encryption.go), which no longer fits the old lengths.
+-- Existing rows are encrypted by running cmd/reencrypt once the keys are configured.
+ALTER TABLE bank_accounts ALTER COLUMN bank_account_number TYPE TEXT;
+ALTER TABLE purchases ALTER COLUMN sender_contact_detail TYPE TEXT;
+
+-- Blind indexes, keyed hashes of the normalized plaintext for equality lookups
+ALTER TABLE bank_accounts ADD COLUMN bank_account_number_bidx VARCHAR(64);
+ALTER TABLE purchases ADD COLUMN sender_contact_bidx VARCHAR(64);
+
+CREATE INDEX idx_bank_accounts_number_bidx ON bank_accounts (user_id, bank_account_number_bidx) WHERE deleted_at IS NULL;
+CREATE INDEX idx_purchases_sender_contact_bidx ON purchases (sender_contact_bidx);
@@ -0,0 +1,2 @@
+-- The original spelling of normalized phones is not kept, there is nothing to revert
+SELECT 1;
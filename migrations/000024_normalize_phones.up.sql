@@ -0,0 +1,32 @@
+-- Phones are stored in E.164 (see utils/phone.go), the same number typed with
+-- separators, a national 0 or a 0 after +62 used to slip past uq_users_phone_not_empty.
+-- This applies the Indonesian rules of the parser to existing rows. A number that would
+-- collide with an account already holding its canonical form is left unchanged for
+-- review, the oldest of several colliding accounts gets the canonical form.
+WITH normalized AS (
+    SELECT id, phone, regexp_replace(
+        regexp_replace(
+            regexp_replace(
+                regexp_replace(phone, '[\s().-]', '', 'g'),
+                '^00', '+'),
+            '^0', '+62'),
+        '^\+620', '+62') AS canonical
+    FROM users
+    WHERE phone IS NOT NULL AND phone <> ''
+),
+changed AS (
+    SELECT id, canonical, ROW_NUMBER() OVER (PARTITION BY canonical ORDER BY id) AS position
+    FROM normalized
+    WHERE canonical <> phone
+)
+UPDATE users u
+SET
+    phone = changed.canonical,
+    updated_at = NOW()
+FROM changed
+WHERE u.id = changed.id
+  AND changed.position = 1
+  AND NOT EXISTS (SELECT 1 FROM users other WHERE other.phone = changed.canonical);
+
+-- Purchases keep the sender contact encrypted, their blind indexes are rebuilt with the
+-- canonical phone by running cmd/reencrypt (make reencrypt) after this migration.
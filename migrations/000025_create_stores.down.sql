@@ -0,0 +1,12 @@
+ALTER TABLE payment_detail
+    DROP COLUMN IF EXISTS confirmed_by,
+    DROP COLUMN IF EXISTS confirmed_at;
+
+DROP INDEX IF EXISTS idx_bank_accounts_store_id;
+DROP INDEX IF EXISTS idx_products_store_id;
+ALTER TABLE bank_accounts DROP COLUMN IF EXISTS store_id;
+ALTER TABLE products DROP COLUMN IF EXISTS store_id;
+
+DROP TABLE IF EXISTS store_invitations;
+DROP TABLE IF EXISTS store_members;
+DROP TABLE IF EXISTS stores;
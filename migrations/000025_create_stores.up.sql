@@ -0,0 +1,59 @@
+-- A store is the organization behind a seller account. It owns the products and
+-- bank accounts, the owner can let staff act on it with a subset of permissions.
+-- products.user_id and bank_accounts.user_id keep pointing at the owner.
+CREATE TABLE stores (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The owner is not a member, owners hold every permission
+CREATE TABLE store_members (
+    store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (store_id, user_id)
+);
+
+CREATE INDEX idx_store_members_user_id ON store_members (user_id);
+
+-- Invitations are sent to an email or a phone, only the SHA-256 hash of the token is stored
+CREATE TABLE store_invitations (
+    id SERIAL PRIMARY KEY,
+    store_id INTEGER NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    phone VARCHAR(20),
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((email IS NULL) <> (phone IS NULL))
+);
+
+CREATE INDEX idx_store_invitations_store_id ON store_invitations (store_id) WHERE accepted_at IS NULL;
+
+ALTER TABLE products ADD COLUMN store_id INTEGER REFERENCES stores(id) ON DELETE RESTRICT;
+ALTER TABLE bank_accounts ADD COLUMN store_id INTEGER REFERENCES stores(id) ON DELETE RESTRICT;
+
+-- Every account that already sells gets its store
+INSERT INTO stores (owner_id)
+SELECT user_id FROM products WHERE user_id IS NOT NULL
+UNION
+SELECT user_id FROM bank_accounts
+ON CONFLICT (owner_id) DO NOTHING;
+
+UPDATE products p SET store_id = s.id FROM stores s WHERE s.owner_id = p.user_id;
+UPDATE bank_accounts b SET store_id = s.id FROM stores s WHERE s.owner_id = b.user_id;
+
+CREATE INDEX idx_products_store_id ON products (store_id);
+CREATE INDEX idx_bank_accounts_store_id ON bank_accounts (store_id) WHERE deleted_at IS NULL;
+
+-- Staff with the payments permission confirm that a seller's share was received
+ALTER TABLE payment_detail
+    ADD COLUMN confirmed_at TIMESTAMPTZ,
+    ADD COLUMN confirmed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
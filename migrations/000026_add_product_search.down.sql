@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS trg_products_search ON products;
+DROP FUNCTION IF EXISTS refresh_product_search();
+DROP INDEX IF EXISTS idx_products_name_trgm;
+DROP TABLE IF EXISTS product_search;
+-- pg_trgm is left installed, other database objects may use it
@@ -0,0 +1,41 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The search document of a product lives next to it rather than on products, so the
+-- product queries keep their column lists. Product names are mostly Indonesian, the
+-- simple configuration does not stem them with English rules.
+CREATE TABLE product_search (
+    product_id INTEGER PRIMARY KEY REFERENCES products(product_id) ON DELETE CASCADE,
+    search_vector TSVECTOR NOT NULL
+);
+
+CREATE INDEX idx_product_search_vector ON product_search USING GIN (search_vector);
+
+-- Typos are matched by trigram similarity on the name
+CREATE INDEX idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+
+-- The name weighs most, then the SKU, then the category
+CREATE FUNCTION refresh_product_search() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO product_search (product_id, search_vector)
+    VALUES (
+        NEW.product_id,
+        setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(NEW.sku, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE((SELECT name FROM product_category WHERE product_category_id = NEW.category), '')), 'C')
+    )
+    ON CONFLICT (product_id) DO UPDATE SET search_vector = EXCLUDED.search_vector;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_products_search
+    AFTER INSERT OR UPDATE OF name, sku, category ON products
+    FOR EACH ROW EXECUTE FUNCTION refresh_product_search();
+
+INSERT INTO product_search (product_id, search_vector)
+SELECT p.product_id,
+       setweight(to_tsvector('simple', COALESCE(p.name, '')), 'A') ||
+       setweight(to_tsvector('simple', COALESCE(p.sku, '')), 'B') ||
+       setweight(to_tsvector('simple', COALESCE(pc.name, '')), 'C')
+FROM products p
+         LEFT JOIN product_category pc ON pc.product_category_id = p.category;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_product_category_parent_id;
+ALTER TABLE product_category
+    DROP CONSTRAINT IF EXISTS chk_product_category_parent,
+    DROP CONSTRAINT IF EXISTS uq_product_category_slug,
+    DROP CONSTRAINT IF EXISTS uq_product_category_name,
+    ALTER COLUMN name DROP NOT NULL,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS is_active,
+    DROP COLUMN IF EXISTS slug,
+    DROP COLUMN IF EXISTS parent_id;
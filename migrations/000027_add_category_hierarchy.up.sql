@@ -0,0 +1,30 @@
+-- Categories are managed by admins, they nest under a parent and can be hidden
+-- without touching the products filed under them.
+ALTER TABLE product_category
+    ADD COLUMN parent_id INTEGER REFERENCES product_category(product_category_id) ON DELETE RESTRICT,
+    ADD COLUMN slug VARCHAR(64),
+    ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE product_category
+SET name = 'Category ' || product_category_id
+WHERE name IS NULL OR name = '';
+
+UPDATE product_category
+SET slug = TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g')) || '-' || product_category_id
+WHERE TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g')) = '';
+
+UPDATE product_category
+SET slug = TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g'))
+WHERE slug IS NULL;
+
+-- Products pick their category by name, names are unique across the tree
+ALTER TABLE product_category
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT uq_product_category_name UNIQUE (name),
+    ADD CONSTRAINT uq_product_category_slug UNIQUE (slug),
+    ADD CONSTRAINT chk_product_category_parent CHECK (parent_id <> product_category_id);
+
+CREATE INDEX idx_product_category_parent_id ON product_category (parent_id);
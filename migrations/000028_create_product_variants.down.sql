@@ -0,0 +1,3 @@
+ALTER TABLE purchase_item DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variants;
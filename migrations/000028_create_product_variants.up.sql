@@ -0,0 +1,21 @@
+-- A variant is one purchasable option of a product, e.g. a size or color, with its own stock.
+-- Its price and image override the product's when set.
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    -- The seller the SKU is unique for, the product's user_id
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sku VARCHAR(32) NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}',
+    qty INTEGER NOT NULL DEFAULT 0,
+    price DECIMAL,
+    file_id INTEGER REFERENCES files(id) ON DELETE SET NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_variant_sku_per_user UNIQUE (user_id, sku)
+);
+
+CREATE INDEX idx_product_variants_product_id ON product_variants(product_id);
+
+ALTER TABLE purchase_item ADD COLUMN variant_id INTEGER REFERENCES product_variants(id) ON DELETE SET NULL;
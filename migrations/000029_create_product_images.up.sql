@@ -0,0 +1,19 @@
+-- A product's gallery in display order. The primary image is the one kept in products.file_id.
+CREATE TABLE product_images (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    sort_order INTEGER NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_product_images_file UNIQUE (product_id, file_id)
+);
+
+CREATE INDEX idx_product_images_product_id ON product_images(product_id, sort_order);
+CREATE UNIQUE INDEX uq_product_images_primary ON product_images(product_id) WHERE is_primary;
+
+-- Existing products start with their single image
+INSERT INTO product_images (product_id, file_id, sort_order, is_primary)
+SELECT product_id, file_id, 0, TRUE
+FROM products
+WHERE file_id IS NOT NULL;
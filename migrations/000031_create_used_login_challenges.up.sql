@@ -0,0 +1,10 @@
+-- Table: used_login_challenges
+-- 2FA login challenges (by jti) that were already redeemed for a session, kept until they expire.
+CREATE TABLE used_login_challenges (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_used_login_challenges_expires_at ON used_login_challenges (expires_at);
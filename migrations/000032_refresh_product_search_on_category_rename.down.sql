@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trg_product_category_search ON product_category;
+DROP FUNCTION IF EXISTS refresh_category_product_search();
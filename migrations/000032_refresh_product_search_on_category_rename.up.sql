@@ -0,0 +1,30 @@
+-- Products are searchable by their category's name, renaming a category refreshes
+-- the search documents of its products in the same statement
+CREATE FUNCTION refresh_category_product_search() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE product_search ps
+    SET search_vector =
+            setweight(to_tsvector('simple', COALESCE(p.name, '')), 'A') ||
+            setweight(to_tsvector('simple', COALESCE(p.sku, '')), 'B') ||
+            setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'C')
+    FROM products p
+    WHERE p.product_id = ps.product_id AND p.category = NEW.product_category_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_product_category_search
+    AFTER UPDATE OF name ON product_category
+    FOR EACH ROW
+    WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION refresh_category_product_search();
+
+-- Categories renamed before this migration left stale documents behind
+UPDATE product_search ps
+SET search_vector =
+        setweight(to_tsvector('simple', COALESCE(p.name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(p.sku, '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(pc.name, '')), 'C')
+FROM products p
+         LEFT JOIN product_category pc ON pc.product_category_id = p.category
+WHERE p.product_id = ps.product_id;
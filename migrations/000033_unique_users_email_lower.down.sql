@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS uq_users_email_lower;
+CREATE UNIQUE INDEX uq_users_email_not_empty ON users (email) WHERE email <> '';
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
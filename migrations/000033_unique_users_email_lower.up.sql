@@ -0,0 +1,26 @@
+-- Emails are matched case-insensitively, so case variants of one address must not belong
+-- to different accounts. Which account keeps such an address is for support to decide,
+-- the migration stops and lists the collisions instead of picking one.
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(address || ' (users ' || ids || ')', '; ')
+    INTO collisions
+    FROM (
+        SELECT lower(email) AS address, string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM users
+        WHERE email <> ''
+        GROUP BY lower(email)
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'Emails used by more than one account in different case: %', collisions
+            USING HINT = 'Change or clear the email of all but one account of each address, then migrate again';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_users_email_lower;
+DROP INDEX IF EXISTS uq_users_email_not_empty;
+CREATE UNIQUE INDEX uq_users_email_lower ON users (lower(email)) WHERE email <> '';
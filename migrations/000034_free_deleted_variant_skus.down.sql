@@ -0,0 +1,11 @@
+-- Deactivated variants may share their SKU with a live variant or with each other,
+-- they are renamed apart so the plain constraint can be restored
+UPDATE product_variants v
+SET sku = LEFT(v.sku, 32 - LENGTH('-' || v.id)) || '-' || v.id
+WHERE NOT v.is_active
+  AND EXISTS (
+      SELECT 1 FROM product_variants o
+      WHERE o.user_id = v.user_id AND o.sku = v.sku AND o.id <> v.id
+  );
+DROP INDEX IF EXISTS unique_variant_sku_per_user;
+ALTER TABLE product_variants ADD CONSTRAINT unique_variant_sku_per_user UNIQUE (user_id, sku);
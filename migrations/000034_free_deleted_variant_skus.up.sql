@@ -0,0 +1,11 @@
+-- Variants of products deleted before their variants were deactivated with them
+UPDATE product_variants v
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+FROM products p
+WHERE p.product_id = v.product_id AND NOT p.is_active AND v.is_active;
+
+-- Like products, a deleted variant no longer blocks its SKU
+ALTER TABLE product_variants DROP CONSTRAINT IF EXISTS unique_variant_sku_per_user;
+CREATE UNIQUE INDEX unique_variant_sku_per_user ON product_variants (user_id, sku) WHERE is_active;
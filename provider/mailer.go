@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"tutuplapak-go/config"
+)
+
+// Mailer delivers plain text emails
+type Mailer interface {
+	SendMail(ctx context.Context, to string, subject string, body string) error
+}
+
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func NewMailer(cfg config.MailConfig) Mailer {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			log.Fatal("SMTP_HOST is required when MAIL_DRIVER=smtp")
+		}
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPass,
+			From:     cfg.From,
+		}
+	case "file":
+		return &FileMailer{Path: cfg.FilePath}
+	case "memory":
+		return &MemoryMailer{}
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q", cfg.Driver)
+		return nil
+	}
+}
+
+// SMTPMailer sends through an SMTP server, authenticating when a username is set
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := buildMessage(m.From, to, subject, body)
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, from.Address, []string{to}, msg)
+}
+
+// FileMailer appends messages to a file, for local development
+type FileMailer struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func (m *FileMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\n%s\n", buildMessage("", to, subject, body), strings.Repeat("-", 72))
+	return err
+}
+
+// MemoryMailer keeps messages in memory so tests can inspect them
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []MailMessage
+}
+
+func (m *MemoryMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.messages = append(m.messages, MailMessage{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Messages returns a copy of every message sent so far
+func (m *MemoryMailer) Messages() []MailMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeHeader strips header injection attempts from user influenced header values
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"tutuplapak-go/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OpenID Connect issuer. The token endpoint answers with
+// whatever ID token the test put into idToken after checking the PKCE verifier.
+type mockIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	idToken   string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "mock-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "id_token": m.idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock-key"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockIssuer) provider() *OIDCProvider {
+	return NewOIDCProviders([]config.OIDCProviderConfig{{
+		Name:      "mock",
+		IssuerURL: m.server.URL,
+		ClientID:  "tutuplapak",
+		Scopes:    []string{"openid", "email"},
+	}}, "http://api.test")["mock"]
+}
+
+func TestOIDCProviderLoginFlow(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	verifier, challenge := NewPKCEVerifier()
+	m.challenge = challenge
+
+	authURL, err := p.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	for param, want := range map[string]string{
+		"client_id":             "tutuplapak",
+		"redirect_uri":          "http://api.test/v1/login/oidc/mock/callback",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+
+	m.idToken = m.sign(t, jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            "tutuplapak",
+		"sub":            "user-1",
+		"email":          "Seller@Example.com",
+		"email_verified": "true",
+		"nonce":          "the-nonce",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+
+	if _, err := p.Exchange(ctx, "good-code", "wrong-verifier"); err == nil {
+		t.Error("exchange with a wrong code verifier succeeded")
+	}
+	idToken, err := p.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := p.VerifyIDToken(ctx, idToken, "the-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "seller@example.com" || !identity.EmailVerified {
+		t.Errorf("identity = %+v", identity)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidIDTokens(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            m.server.URL,
+			"aud":            "tutuplapak",
+			"sub":            "user-1",
+			"email":          "seller@example.com",
+			"email_verified": true,
+			"nonce":          "the-nonce",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		nonce  string
+	}{
+		{"wrong nonce", func(jwt.MapClaims) {}, "other-nonce"},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }, "the-nonce"},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, "the-nonce"},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, "the-nonce"},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }, "the-nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			if _, err := p.VerifyIDToken(ctx, m.sign(t, claims), tt.nonce); err == nil {
+				t.Error("invalid ID token was accepted")
+			}
+		})
+	}
+
+	t.Run("foreign signing key", func(t *testing.T) {
+		other := newMockIssuer(t)
+		if _, err := p.VerifyIDToken(ctx, other.sign(t, valid()), "the-nonce"); err == nil {
+			t.Error("ID token signed by another key was accepted")
+		}
+	})
+}
+
+func TestOIDCProviderNormalizesEmailCase(t *testing.T) {
+	m := newMockIssuer(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	// Every case variant must resolve to the same account email
+	for _, email := range []string{"seller@example.com", "Seller@Example.com", "SELLER@EXAMPLE.COM"} {
+		identity, err := p.VerifyIDToken(ctx, m.sign(t, jwt.MapClaims{
+			"iss":            m.server.URL,
+			"aud":            "tutuplapak",
+			"sub":            "user-1",
+			"email":          email,
+			"email_verified": true,
+			"nonce":          "the-nonce",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}), "the-nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Email != "seller@example.com" {
+			t.Errorf("email %q became %q, want %q", email, identity.Email, "seller@example.com")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"tutuplapak-go/config"
+	"tutuplapak-go/utils"
+)
+
+// SMSSender delivers text messages to an E.164 phone number
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone string, message string) error
+}
+
+func NewSMSSender(cfg config.SMSConfig) SMSSender {
+	switch cfg.Driver {
+	case "log":
+		return &LogSMSSender{}
+	case "file":
+		return &FileSMSSender{Path: cfg.FilePath}
+	default:
+		log.Fatalf("Unknown SMS_DRIVER %q", cfg.Driver)
+		return nil
+	}
+}
+
+// LogSMSSender writes messages to the application log, for local development only
+type LogSMSSender struct{}
+
+func (s *LogSMSSender) SendSMS(ctx context.Context, phone string, message string) error {
+	utils.Logger.Info().Str("to", phone).Str("message", message).Msg("SMS")
+	return nil
+}
+
+// FileSMSSender appends messages to a file, for local development and tests
+type FileSMSSender struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func (s *FileSMSSender) SendSMS(ctx context.Context, phone string, message string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message)
+	return err
+}
@@ -0,0 +1,44 @@
+-- name: AnonymizeUser :execrows
+-- Personal data is cleared but the row stays, purchases of the user's products keep their seller.
+UPDATE users
+SET
+    file_id = NULL,
+    store_name = NULL,
+    email = NULL,
+    phone = NULL,
+    pending_email = NULL,
+    password = $2,
+    phone_verified_at = NULL,
+    email_verified_at = NULL,
+    totp_secret = NULL,
+    totp_enabled_at = NULL,
+    totp_last_step = NULL,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DeactivateUserProducts :exec
+UPDATE products
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE user_id = $1 AND is_active;
+
+-- name: ListProductsByUserID :many
+-- Includes deactivated products, they are still part of the user's data.
+SELECT
+    p.product_id, p.name, pc.name as category_name, p.qty, p.price, p.sku, p.file_id, p.is_active, p.created_at, p.updated_at
+FROM products p
+         LEFT JOIN product_category pc ON p.category = pc.product_category_id
+WHERE p.user_id = $1
+ORDER BY p.product_id;
+
+-- name: ListPurchaseItemsBySellerID :many
+SELECT
+    pu.id as purchase_id, pu.is_paid, pu.created_at,
+    pi.product_id, p.name, p.sku, pi.qty, pi.total
+FROM purchase_item pi
+         JOIN purchases pu ON pi.purchase_id = pu.id
+         JOIN products p ON pi.product_id = p.product_id
+WHERE p.user_id = $1
+ORDER BY pu.id, pi.id;
@@ -0,0 +1,10 @@
+-- name: ListBanks :many
+SELECT code, name, kind, account_number_min_length, account_number_max_length, account_number_pattern, is_active
+FROM banks
+WHERE is_active
+ORDER BY kind, name;
+
+-- name: GetBankByCode :one
+SELECT code, name, kind, account_number_min_length, account_number_max_length, account_number_pattern, is_active
+FROM banks
+WHERE code = $1 AND is_active;
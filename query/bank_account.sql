@@ -0,0 +1,116 @@
+-- name: CreateBankAccount :one
+-- bank_account_number is an encrypted envelope, look it up through its blind index.
+INSERT INTO bank_accounts (user_id, store_id, bank_code, bank_account_name, bank_account_holder, bank_account_number, bank_account_number_bidx, is_enabled)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id;
+
+-- name: GetBankAccount :one
+SELECT id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id
+FROM bank_accounts
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: GetDefaultBankAccount :one
+SELECT id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id
+FROM bank_accounts
+WHERE user_id = $1 AND is_default AND deleted_at IS NULL;
+
+-- name: ListBankAccountsByUserID :many
+SELECT id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id
+FROM bank_accounts
+WHERE user_id = $1 AND deleted_at IS NULL
+ORDER BY is_default DESC, id;
+
+-- name: ListEnabledBankAccountsByStoreID :many
+SELECT id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id
+FROM bank_accounts
+WHERE store_id = $1 AND is_enabled AND deleted_at IS NULL
+ORDER BY is_default DESC, id;
+
+-- name: UpdateBankAccount :one
+UPDATE bank_accounts
+SET
+    bank_code = $3,
+    bank_account_name = $4,
+    bank_account_holder = $5,
+    bank_account_number = $6,
+    bank_account_number_bidx = $7,
+    is_enabled = $8,
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    RETURNING id, user_id, bank_account_name, bank_account_holder, bank_account_number, is_default, is_enabled, created_at, updated_at, deleted_at, bank_code, bank_account_number_bidx, store_id;
+
+-- name: BankAccountNumberExists :one
+-- Checks the user's other accounts, pass 0 as id for a new account.
+SELECT EXISTS (
+    SELECT 1 FROM bank_accounts
+    WHERE user_id = $1 AND id <> $2 AND bank_code = $3 AND bank_account_number_bidx = $4 AND deleted_at IS NULL
+);
+
+-- name: ClearDefaultBankAccount :exec
+UPDATE bank_accounts
+SET
+    is_default = FALSE,
+    updated_at = NOW()
+WHERE user_id = $1 AND is_default;
+
+-- name: SetDefaultBankAccount :execrows
+-- Only an enabled account can be the default, clear the current default first.
+UPDATE bank_accounts
+SET
+    is_default = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND is_enabled AND deleted_at IS NULL;
+
+-- name: EnsureDefaultBankAccount :exec
+-- Makes the oldest enabled account the default when the user has none.
+UPDATE bank_accounts
+SET
+    is_default = TRUE,
+    updated_at = NOW()
+WHERE id = (
+    SELECT b.id FROM bank_accounts b
+    WHERE b.user_id = $1 AND b.is_enabled AND b.deleted_at IS NULL
+    ORDER BY b.id
+    LIMIT 1
+) AND NOT EXISTS (
+    SELECT 1 FROM bank_accounts d
+    WHERE d.user_id = $1 AND d.is_default AND d.deleted_at IS NULL
+);
+
+-- name: DeleteBankAccount :execrows
+-- Purchases may still point at the account, so it is only hidden.
+UPDATE bank_accounts
+SET
+    is_default = FALSE,
+    is_enabled = FALSE,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: DeleteUserBankAccounts :exec
+-- Used when the account is deleted, the rows stay for purchases but lose the personal data.
+UPDATE bank_accounts
+SET
+    bank_account_holder = '',
+    bank_account_number = '',
+    bank_account_number_bidx = NULL,
+    is_default = FALSE,
+    is_enabled = FALSE,
+    deleted_at = COALESCE(deleted_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1;
+
+-- name: ListBankAccountsForReencryption :many
+-- Walks every account in id order, including deleted ones, for cmd/reencrypt.
+SELECT id, bank_account_number, bank_account_number_bidx
+FROM bank_accounts
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ReencryptBankAccountNumber :exec
+UPDATE bank_accounts
+SET
+    bank_account_number = $2,
+    bank_account_number_bidx = $3
+WHERE id = $1;
@@ -0,0 +1,47 @@
+-- name: ListCategories :many
+SELECT product_category_id, name, parent_id, slug, is_active, created_at, updated_at
+FROM product_category
+ORDER BY name;
+
+-- name: GetCategoryByID :one
+SELECT product_category_id, name, parent_id, slug, is_active, created_at, updated_at
+FROM product_category
+WHERE product_category_id = $1;
+
+-- name: CreateCategory :one
+INSERT INTO product_category (name, slug, parent_id, is_active)
+VALUES ($1, $2, $3, $4)
+    RETURNING product_category_id, name, parent_id, slug, is_active, created_at, updated_at;
+
+-- name: UpdateCategory :one
+UPDATE product_category
+SET
+    name = $2,
+    slug = $3,
+    parent_id = $4,
+    is_active = $5,
+    updated_at = NOW()
+WHERE product_category_id = $1
+    RETURNING product_category_id, name, parent_id, slug, is_active, created_at, updated_at;
+
+-- name: DeleteCategory :execrows
+DELETE FROM product_category
+WHERE product_category_id = $1;
+
+-- name: CountCategoryUsage :one
+-- A category with subcategories or products, even inactive ones, can not be deleted.
+SELECT
+    (SELECT COUNT(*) FROM product_category c WHERE c.parent_id = $1) AS children,
+    (SELECT COUNT(*) FROM products p WHERE p.category = $1) AS products;
+
+-- name: IsCategoryInSubtree :one
+-- Reports whether category_id is root_id or one of its descendants, moving a category
+-- under its own subtree would make a cycle.
+WITH RECURSIVE subtree AS (
+    SELECT product_category_id FROM product_category WHERE product_category_id = sqlc.arg('root_id')
+    UNION ALL
+    SELECT c.product_category_id
+    FROM product_category c
+             JOIN subtree s ON c.parent_id = s.product_category_id
+)
+SELECT EXISTS (SELECT 1 FROM subtree WHERE product_category_id = sqlc.arg('category_id'));
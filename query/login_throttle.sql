@@ -0,0 +1,47 @@
+-- name: GetLoginThrottle :one
+SELECT key, failures, locked_until, last_failure_at
+FROM login_throttles
+WHERE key = $1;
+
+-- name: ReserveLoginAttempt :one
+-- Counts an attempt before the password is compared, so concurrent guesses can not all pass
+-- before a failure is recorded. Counting starts over when the previous attempt happened before
+-- the reset cutoff. Past the free attempts the key is locked, the delay doubling with every
+-- attempt up to lock_max_seconds. A locked key is left untouched and no row is returned.
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET
+    failures = CASE WHEN login_throttles.last_failure_at < $2 THEN 1 ELSE login_throttles.failures + 1 END,
+    locked_until = CASE
+        WHEN login_throttles.last_failure_at >= $2 AND login_throttles.failures + 1 > sqlc.arg('free_attempts')::INT
+            THEN NOW() + LEAST(
+                sqlc.arg('lock_base_seconds')::FLOAT8 * POWER(2, LEAST(login_throttles.failures - sqlc.arg('free_attempts')::INT, 30)),
+                sqlc.arg('lock_max_seconds')::FLOAT8
+            ) * INTERVAL '1 second'
+        END,
+    last_failure_at = NOW()
+WHERE login_throttles.locked_until IS NULL OR login_throttles.locked_until <= NOW()
+    RETURNING failures;
+
+-- name: ReleaseLoginAttempt :exec
+-- Takes back the attempt reserved for a login that succeeded, and the lock it caused.
+UPDATE login_throttles
+SET
+    failures = GREATEST(failures - 1, 0),
+    locked_until = CASE WHEN failures - 1 > sqlc.arg('free_attempts')::INT THEN locked_until END
+WHERE key = $1;
+
+-- name: ListLoginThrottles :many
+SELECT key, failures, locked_until, last_failure_at
+FROM login_throttles
+WHERE locked_until > NOW() OR last_failure_at > $1
+ORDER BY last_failure_at DESC
+LIMIT $2;
+
+-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles WHERE key = $1;
+
+-- name: DeleteStaleLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW());
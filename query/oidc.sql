@@ -0,0 +1,24 @@
+-- name: CreateOIDCState :exec
+INSERT INTO oidc_states (state_hash, provider, code_verifier, nonce, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeOIDCState :one
+-- Deleting the state makes it single use, a replayed callback finds nothing.
+DELETE FROM oidc_states
+WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+    RETURNING state_hash, provider, code_verifier, nonce, expires_at, created_at;
+
+-- name: DeleteExpiredOIDCStates :exec
+DELETE FROM oidc_states WHERE expires_at < NOW();
+
+-- name: GetUserIdentity :one
+SELECT id, user_id, provider, subject, email, created_at
+FROM user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteUserIdentities :exec
+DELETE FROM user_identities WHERE user_id = $1;
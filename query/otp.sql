@@ -0,0 +1,37 @@
+-- name: CreatePhoneOTP :one
+INSERT INTO phone_otps (user_id, phone, purpose, code_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, user_id, phone, purpose, code_hash, attempts, expires_at, consumed_at, created_at;
+
+-- name: GetLatestPhoneOTP :one
+SELECT id, user_id, phone, purpose, code_hash, attempts, expires_at, consumed_at, created_at
+FROM phone_otps
+WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: CountPhoneOTPsSince :one
+SELECT COUNT(*) FROM phone_otps
+WHERE phone = $1 AND purpose = $2 AND created_at > $3;
+
+-- name: ReservePhoneOTPAttempt :one
+-- Counts a guess before the code is compared, so concurrent guesses can not exceed the
+-- limit. A code that is used up or consumed returns no row.
+UPDATE phone_otps
+SET attempts = attempts + 1
+WHERE id = sqlc.arg('id') AND attempts < sqlc.arg('max_attempts') AND consumed_at IS NULL
+    RETURNING attempts;
+
+-- name: ConsumePhoneOTP :execrows
+UPDATE phone_otps
+SET consumed_at = NOW()
+WHERE id = $1 AND consumed_at IS NULL;
+
+-- name: InvalidatePhoneOTPs :exec
+-- Older codes stop working as soon as a new one is sent.
+UPDATE phone_otps
+SET consumed_at = NOW()
+WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL;
+
+-- name: DeleteExpiredPhoneOTPs :exec
+DELETE FROM phone_otps WHERE expires_at < NOW() - INTERVAL '1 day';
@@ -0,0 +1,26 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: GetPasswordResetTokenByHash :one
+SELECT id, user_id, token_hash, expires_at, consumed_at, created_at
+FROM password_reset_tokens
+WHERE token_hash = $1 AND consumed_at IS NULL AND expires_at > NOW();
+
+-- name: CountPasswordResetTokensSince :one
+SELECT COUNT(*) FROM password_reset_tokens
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: ConsumePasswordResetToken :execrows
+UPDATE password_reset_tokens
+SET consumed_at = NOW()
+WHERE id = $1 AND consumed_at IS NULL;
+
+-- name: InvalidateUserPasswordResetTokens :exec
+-- Older links stop working as soon as a new one is sent or the password changes.
+UPDATE password_reset_tokens
+SET consumed_at = NOW()
+WHERE user_id = $1 AND consumed_at IS NULL;
+
+-- name: DeleteExpiredPasswordResetTokens :exec
+DELETE FROM password_reset_tokens WHERE expires_at < NOW() - INTERVAL '1 day';
@@ -1,38 +1,98 @@
 -- name: CreateProduct :one
-INSERT INTO products (user_id, name, category, qty, price, sku, file_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at;
+INSERT INTO products (user_id, store_id, name, category, qty, price, sku, file_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id;
 
 -- name: GetProductByID :one
-SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE product_id = $1;
+WHERE product_id = $1 AND is_active;
 
 -- name: GetProductBySKUAndUserID :one
-SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE sku = $1 AND user_id = $2;
+WHERE sku = $1 AND user_id = $2 AND is_active;
 
 -- name: GetProductCategoryByName :one
-SELECT product_category_id FROM product_category WHERE name = $1;
+SELECT product_category_id, name, parent_id, slug, is_active, created_at, updated_at
+FROM product_category
+WHERE name = $1;
+
+-- name: CountProducts :one
+-- Same filters as ListProducts, for the optional total of a listing.
+SELECT COUNT(*)
+FROM products p
+         JOIN product_category pc ON p.category = pc.product_category_id
+         LEFT JOIN product_search ps ON ps.product_id = p.product_id
+WHERE
+    p.is_active AND
+    (sqlc.narg('product_id')::INT IS NULL OR p.product_id = sqlc.narg('product_id')) AND
+    (sqlc.narg('sku')::TEXT IS NULL OR p.sku = sqlc.narg('sku')) AND
+    (sqlc.narg('category')::TEXT IS NULL OR p.category IN (
+        WITH RECURSIVE subtree AS (
+            SELECT product_category_id FROM product_category WHERE name = sqlc.narg('category')
+            UNION ALL
+            SELECT c.product_category_id
+            FROM product_category c
+                     JOIN subtree s ON c.parent_id = s.product_category_id
+        )
+        SELECT product_category_id FROM subtree
+    )) AND
+    (sqlc.narg('seller_id')::INT IS NULL OR p.user_id = sqlc.narg('seller_id')) AND
+    (sqlc.narg('q')::TEXT IS NULL OR
+     ps.search_vector @@ websearch_to_tsquery('simple', sqlc.narg('q')) OR
+     sqlc.narg('q') <% p.name);
 
 -- name: ListProducts :many
+-- A category matches its subcategories too. With q the products are matched on their
+-- search document or, for typos, by trigram similarity of the name. sort_by is one of
+-- newest, oldest, cheapest, expensive and relevance, the product id breaks ties so that
+-- the cursor_* position of the last product of a page continues the listing where it stopped.
 SELECT
     p.product_id, p.user_id, p.name, pc.name as category_name, p.qty, p.price, p.sku, p.file_id, p.created_at, p.updated_at,
-    f.file_uri, f.file_thumnail_uri
+    f.file_uri, f.file_thumnail_uri, r.relevance
 FROM products p
          JOIN product_category pc ON p.category = pc.product_category_id
          LEFT JOIN files f on p.file_id = f.id
+         LEFT JOIN product_search ps ON ps.product_id = p.product_id
+         CROSS JOIN LATERAL (
+    SELECT CAST(CASE WHEN sqlc.narg('q')::TEXT IS NULL THEN 0 ELSE
+        COALESCE(ts_rank(ps.search_vector, websearch_to_tsquery('simple', sqlc.narg('q'))), 0) +
+        word_similarity(sqlc.narg('q'), p.name)
+    END AS FLOAT8) AS relevance
+) r
 WHERE
+    p.is_active AND
     (sqlc.narg('product_id')::INT IS NULL OR p.product_id = sqlc.narg('product_id')) AND
     (sqlc.narg('sku')::TEXT IS NULL OR p.sku = sqlc.narg('sku')) AND
-    (sqlc.narg('category')::TEXT IS NULL OR pc.name = sqlc.narg('category'))
+    (sqlc.narg('category')::TEXT IS NULL OR p.category IN (
+        WITH RECURSIVE subtree AS (
+            SELECT product_category_id FROM product_category WHERE name = sqlc.narg('category')
+            UNION ALL
+            SELECT c.product_category_id
+            FROM product_category c
+                     JOIN subtree s ON c.parent_id = s.product_category_id
+        )
+        SELECT product_category_id FROM subtree
+    )) AND
+    (sqlc.narg('seller_id')::INT IS NULL OR p.user_id = sqlc.narg('seller_id')) AND
+    (sqlc.narg('q')::TEXT IS NULL OR
+     ps.search_vector @@ websearch_to_tsquery('simple', sqlc.narg('q')) OR
+     sqlc.narg('q') <% p.name) AND
+    (sqlc.narg('cursor_id')::INT IS NULL OR
+     (sqlc.arg('sort_by')::TEXT = 'newest' AND (p.created_at, p.product_id) < (sqlc.narg('cursor_created_at')::TIMESTAMPTZ, sqlc.narg('cursor_id'))) OR
+     (sqlc.arg('sort_by')::TEXT = 'oldest' AND (p.created_at, p.product_id) > (sqlc.narg('cursor_created_at')::TIMESTAMPTZ, sqlc.narg('cursor_id'))) OR
+     (sqlc.arg('sort_by')::TEXT = 'cheapest' AND (p.price, p.product_id) > (sqlc.narg('cursor_price')::DECIMAL, sqlc.narg('cursor_id'))) OR
+     (sqlc.arg('sort_by')::TEXT = 'expensive' AND (p.price, p.product_id) < (sqlc.narg('cursor_price')::DECIMAL, sqlc.narg('cursor_id'))) OR
+     (sqlc.arg('sort_by')::TEXT = 'relevance' AND (r.relevance, p.product_id) < (sqlc.narg('cursor_relevance')::FLOAT8, sqlc.narg('cursor_id'))))
 ORDER BY
-    CASE WHEN sqlc.narg('sort_by')::TEXT = 'newest' THEN p.created_at END DESC,
-    CASE WHEN sqlc.narg('sort_by')::TEXT = 'oldest' THEN p.created_at END ASC,
-    CASE WHEN sqlc.narg('sort_by')::TEXT = 'cheapest' THEN p.price END ASC,
-    CASE WHEN sqlc.narg('sort_by')::TEXT = 'expensive' THEN p.price END DESC,
-    p.created_at DESC
+    CASE WHEN sqlc.arg('sort_by')::TEXT = 'relevance' THEN r.relevance END DESC,
+    CASE WHEN sqlc.arg('sort_by')::TEXT = 'newest' THEN p.created_at END DESC,
+    CASE WHEN sqlc.arg('sort_by')::TEXT = 'oldest' THEN p.created_at END ASC,
+    CASE WHEN sqlc.arg('sort_by')::TEXT = 'cheapest' THEN p.price END ASC,
+    CASE WHEN sqlc.arg('sort_by')::TEXT = 'expensive' THEN p.price END DESC,
+    CASE WHEN sqlc.arg('sort_by')::TEXT IN ('oldest', 'cheapest') THEN p.product_id END ASC,
+    p.product_id DESC
     LIMIT sqlc.arg('limit')
 OFFSET sqlc.arg('offset');
 
@@ -46,9 +106,13 @@ SET
     sku = $6,
     file_id = $7,
     updated_at = NOW()
-WHERE product_id = $1 AND user_id = $8
-RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at;
+WHERE product_id = $1 AND store_id = $8 AND is_active
+RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id;
 
 -- name: DeleteProduct :exec
-DELETE FROM products
-WHERE product_id = $1 AND user_id = $2;
\ No newline at end of file
+-- Products may be part of purchases, deleting one only deactivates it.
+UPDATE products
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE product_id = $1 AND store_id = $2 AND is_active;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- name: ListProductImages :many
+SELECT pi.file_id, pi.sort_order, pi.is_primary, f.file_uri, f.file_thumnail_uri
+FROM product_images pi
+         JOIN files f ON pi.file_id = f.id
+WHERE pi.product_id = $1
+ORDER BY pi.sort_order;
+
+-- name: ListProductImagesByProductIDs :many
+-- Galleries of a page of products in one round trip.
+SELECT pi.product_id, pi.file_id, pi.sort_order, pi.is_primary, f.file_uri, f.file_thumnail_uri
+FROM product_images pi
+         JOIN files f ON pi.file_id = f.id
+WHERE pi.product_id = ANY(sqlc.arg('product_ids')::INT[])
+ORDER BY pi.product_id, pi.sort_order;
+
+-- name: CreateProductImage :exec
+INSERT INTO product_images (product_id, file_id, sort_order, is_primary)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteProductImages :exec
+-- Galleries are replaced as a whole, within the transaction that inserts the new one.
+DELETE FROM product_images WHERE product_id = $1;
@@ -1,27 +1,27 @@
 -- name: GetProductForUpdate :one
 -- This query now only fetches from the products table, without the JOIN.
 SELECT
-    product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+    product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE product_id = $1;
+WHERE product_id = $1 AND is_active;
 
 -- name: GetProductCategoryByID :one
 -- New query to fetch a category name by its ID.
 SELECT name FROM product_category WHERE product_category_id = $1;
 
 -- name: CreatePurchase :one
-INSERT INTO purchases (sender_name, sender_contact_type, sender_contact_detail, total, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
+-- sender_contact_detail is an encrypted envelope, look it up through its blind index.
+INSERT INTO purchases (sender_name, sender_contact_type, sender_contact_detail, sender_contact_bidx, total, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
     RETURNING id, created_at;
 
 -- name: CreatePurchaseItem :exec
-INSERT INTO purchase_item (purchase_id, product_id, qty, total)
-VALUES ($1, $2, $3, $4);
+INSERT INTO purchase_item (purchase_id, product_id, qty, total, variant_id)
+VALUES ($1, $2, $3, $4, $5);
 
--- name: GetSellerBankDetailsByUserID :one
-SELECT bank_account_name, bank_account_holder, bank_account_number
-FROM users
-WHERE id = $1;
+-- name: CreatePurchasePaymentAccount :exec
+INSERT INTO purchase_payment_accounts (purchase_id, bank_account_id)
+VALUES ($1, $2);
 
 -- name: GetPurchaseByID :one
 SELECT id, sender_name, sender_contact_type, sender_contact_detail, total, is_paid, created_at, updated_at
@@ -29,7 +29,7 @@ FROM purchases
 WHERE id = $1;
 
 -- name: GetPurchaseItemsByPurchaseID :many
-SELECT pi.id, pi.purchase_id, pi.product_id, pi.qty, pi.total, p.user_id
+SELECT pi.id, pi.purchase_id, pi.product_id, pi.qty, pi.total, pi.variant_id, p.user_id
 FROM purchase_item pi
 JOIN products p ON pi.product_id = p.product_id
 WHERE pi.purchase_id = $1;
@@ -39,6 +39,11 @@ UPDATE products
 SET qty = qty - $2, updated_at = NOW()
 WHERE product_id = $1;
 
+-- name: UpdateProductVariantQuantity :exec
+UPDATE product_variants
+SET qty = qty - $2, updated_at = NOW()
+WHERE id = $1;
+
 -- name: UpdatePurchasePaymentStatus :exec
 UPDATE purchases 
 SET is_paid = TRUE, updated_at = NOW()
@@ -48,3 +53,24 @@ WHERE id = $1;
 INSERT INTO payment_detail (purchase_id, user_id, file_id)
 VALUES ($1, $2, $3);
 
+-- name: ListPurchasesBySenderContact :many
+-- Purchases a buyer placed with one of their verified contacts.
+SELECT id, sender_name, sender_contact_type, sender_contact_detail, total, is_paid, created_at
+FROM purchases
+WHERE sender_contact_bidx IN (sqlc.narg('email_bidx'), sqlc.narg('phone_bidx'))
+ORDER BY id;
+
+-- name: ListPurchasesForReencryption :many
+-- Walks every purchase in id order for cmd/reencrypt.
+SELECT id, sender_contact_type, sender_contact_detail, sender_contact_bidx
+FROM purchases
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ReencryptPurchaseContact :exec
+UPDATE purchases
+SET
+    sender_contact_detail = $2,
+    sender_contact_bidx = $3
+WHERE id = $1;
@@ -0,0 +1,12 @@
+-- name: GetSellerStorefront :one
+-- Only public fields, the storefront must never expose contact or bank details.
+SELECT
+    u.id, u.store_name, u.file_id, u.created_at,
+    (SELECT COUNT(*) FROM products p WHERE p.user_id = u.id AND p.is_active) AS product_count,
+    (SELECT COUNT(DISTINCT pi.purchase_id)
+     FROM purchase_item pi
+              JOIN purchases pu ON pi.purchase_id = pu.id
+              JOIN products p ON pi.product_id = p.product_id
+     WHERE p.user_id = u.id AND pu.is_paid) AS sales_count
+FROM users u
+WHERE u.id = $1 AND u.deleted_at IS NULL AND u.role <> 'buyer';
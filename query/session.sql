@@ -0,0 +1,49 @@
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, token_hash, user_agent, ip_address, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at;
+
+-- name: GetActiveSessionByTokenHash :one
+SELECT id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+FROM sessions
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = NOW()
+WHERE id = $1;
+
+-- name: ExtendSession :exec
+UPDATE sessions
+SET expires_at = $2, last_seen_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: ListActiveSessionsByUserID :many
+SELECT id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeSessionByTokenHash :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeSessionByID :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :exec
+-- Ends every session of a user except the one identified by the token hash.
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND token_hash <> $2 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at < NOW() OR revoked_at < NOW() - INTERVAL '30 days';
@@ -0,0 +1,118 @@
+-- name: GetOrCreateStore :one
+-- A seller account gets its store the first time it lists a product or adds a bank account.
+INSERT INTO stores (owner_id)
+VALUES ($1)
+ON CONFLICT (owner_id) DO UPDATE SET owner_id = EXCLUDED.owner_id
+    RETURNING id, owner_id, created_at;
+
+-- name: GetStoreByID :one
+SELECT id, owner_id, created_at
+FROM stores
+WHERE id = $1;
+
+-- name: GetStoreMember :one
+SELECT store_id, user_id, permissions, invited_by, created_at, updated_at
+FROM store_members
+WHERE store_id = $1 AND user_id = $2;
+
+-- name: ListUserStores :many
+-- Stores the user owns or works in, permissions is NULL for the owner.
+SELECT s.id, s.owner_id, u.store_name, m.permissions
+FROM stores s
+         JOIN users u ON s.owner_id = u.id
+         LEFT JOIN store_members m ON m.store_id = s.id AND m.user_id = $1
+WHERE s.owner_id = $1 OR m.user_id IS NOT NULL
+ORDER BY s.id;
+
+-- name: ListStoreMembers :many
+SELECT m.user_id, u.email, u.phone, m.permissions, m.created_at
+FROM store_members m
+         JOIN users u ON m.user_id = u.id
+WHERE m.store_id = $1
+ORDER BY m.created_at, m.user_id;
+
+-- name: AddStoreMember :exec
+-- Accepting a second invitation replaces the permissions of the first one.
+INSERT INTO store_members (store_id, user_id, permissions, invited_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (store_id, user_id) DO UPDATE
+    SET permissions = EXCLUDED.permissions, updated_at = NOW();
+
+-- name: UpdateStoreMemberPermissions :execrows
+UPDATE store_members
+SET
+    permissions = $3,
+    updated_at = NOW()
+WHERE store_id = $1 AND user_id = $2;
+
+-- name: RemoveStoreMember :execrows
+DELETE FROM store_members
+WHERE store_id = $1 AND user_id = $2;
+
+-- name: CreateStoreInvitation :one
+INSERT INTO store_invitations (store_id, email, phone, permissions, token_hash, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+    RETURNING id, store_id, email, phone, permissions, token_hash, invited_by, expires_at, accepted_at, created_at;
+
+-- name: GetStoreInvitationByHash :one
+SELECT id, store_id, email, phone, permissions, token_hash, invited_by, expires_at, accepted_at, created_at
+FROM store_invitations
+WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW();
+
+-- name: ListStoreInvitations :many
+-- Invitations still waiting for an answer.
+SELECT id, store_id, email, phone, permissions, token_hash, invited_by, expires_at, accepted_at, created_at
+FROM store_invitations
+WHERE store_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+ORDER BY id;
+
+-- name: AcceptStoreInvitation :execrows
+UPDATE store_invitations
+SET accepted_at = NOW()
+WHERE id = $1 AND accepted_at IS NULL;
+
+-- name: DeleteStoreInvitation :execrows
+DELETE FROM store_invitations
+WHERE id = $1 AND store_id = $2 AND accepted_at IS NULL;
+
+-- name: ListStoreOrders :many
+-- Purchases holding products of the store with the store's share of the total and its payment.
+SELECT
+    pu.id, pu.sender_name, pu.sender_contact_type, pu.sender_contact_detail, pu.is_paid, pu.created_at,
+    CAST(SUM(pi.total) AS BIGINT) AS subtotal,
+    pd.file_id AS payment_file_id, pd.confirmed_at
+FROM purchases pu
+         JOIN purchase_item pi ON pi.purchase_id = pu.id
+         JOIN products p ON pi.product_id = p.product_id
+         LEFT JOIN payment_detail pd ON pd.purchase_id = pu.id AND pd.user_id = p.user_id
+WHERE p.store_id = $1
+GROUP BY pu.id, pd.id
+ORDER BY pu.id DESC
+    LIMIT $2
+OFFSET $3;
+
+-- name: ListStoreOrderItems :many
+SELECT pi.product_id, p.name, p.sku, pi.qty, pi.total, pi.variant_id, v.sku AS variant_sku
+FROM purchase_item pi
+         JOIN products p ON pi.product_id = p.product_id
+         LEFT JOIN product_variants v ON pi.variant_id = v.id
+WHERE pi.purchase_id = $1 AND p.store_id = $2
+ORDER BY pi.id;
+
+-- name: ConfirmStorePayment :execrows
+-- Confirming twice keeps the first confirmation, no rows means the buyer has not paid the store yet.
+UPDATE payment_detail pd
+SET
+    confirmed_at = COALESCE(pd.confirmed_at, NOW()),
+    confirmed_by = COALESCE(pd.confirmed_by, sqlc.arg('confirmed_by'))
+FROM stores s
+WHERE pd.purchase_id = sqlc.arg('purchase_id') AND s.id = sqlc.arg('store_id') AND pd.user_id = s.owner_id;
+
+-- name: DeleteUserStoreMemberships :exec
+-- Used when the account is deleted, it leaves every store and its own store loses its staff.
+DELETE FROM store_members
+WHERE user_id = $1 OR store_id IN (SELECT id FROM stores WHERE owner_id = $1);
+
+-- name: DeleteOwnerStoreInvitations :exec
+DELETE FROM store_invitations
+WHERE accepted_at IS NULL AND store_id IN (SELECT id FROM stores WHERE owner_id = $1);
@@ -0,0 +1,67 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, token_hash, family_id, access_jti, access_expires_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING id, user_id, token_hash, family_id, access_jti, access_expires_at, expires_at, revoked_at, created_at;
+
+-- name: GetRefreshTokenByHash :one
+SELECT id, user_id, token_hash, family_id, access_jti, access_expires_at, expires_at, revoked_at, created_at
+FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :execrows
+-- Only succeeds once per token, a second caller sees zero affected rows.
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
+
+-- name: RevokeFamilyAccessTokens :exec
+-- Blacklists every access token issued within a family that has not expired yet.
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+SELECT access_jti, user_id, access_expires_at
+FROM refresh_tokens
+WHERE family_id = $1 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserAccessTokens :exec
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+SELECT access_jti, user_id, access_expires_at
+FROM refresh_tokens
+WHERE user_id = $1 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeOtherUserAccessTokens :exec
+-- Same as RevokeUserAccessTokens but keeps the tokens of one family alive.
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+SELECT access_jti, user_id, access_expires_at
+FROM refresh_tokens
+WHERE user_id = $1 AND family_id <> $2 AND access_expires_at > NOW()
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_tokens (jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1);
+
+-- name: DeleteExpiredRefreshTokens :exec
+DELETE FROM refresh_tokens WHERE expires_at < NOW();
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens WHERE expires_at < NOW();
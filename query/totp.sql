@@ -0,0 +1,57 @@
+-- name: GetUserTOTP :one
+SELECT totp_secret, totp_enabled_at, totp_last_step
+FROM users
+WHERE id = $1;
+
+-- name: SetTOTPSecret :exec
+-- Starts a new enrollment, 2FA stays off until a code from the secret is verified.
+UPDATE users
+SET
+    totp_secret = $2,
+    totp_enabled_at = NULL,
+    totp_last_step = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET
+    totp_enabled_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET
+    totp_secret = NULL,
+    totp_enabled_at = NULL,
+    totp_last_step = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+-- Only succeeds for a step later than any used before, so a code works once.
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2);
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO totp_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes WHERE user_id = $1;
+
+-- name: UseLoginChallenge :execrows
+-- Redeems a 2FA login challenge, one that was redeemed before affects no row.
+INSERT INTO used_login_challenges (jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: DeleteExpiredLoginChallenges :exec
+DELETE FROM used_login_challenges WHERE expires_at < NOW();
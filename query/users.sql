@@ -15,18 +15,25 @@ VALUES ($1, $2, $3)
     RETURNING id, phone, email, created_at;
 
 -- name: GetUserByEmail :one
+-- Emails are matched case-insensitively, older accounts may be stored in mixed case.
 SELECT id, email, phone, password, created_at
 FROM users
-WHERE email = $1;
+WHERE lower(email) = lower($1);
 
 -- name: GetUserByPhone :one
 SELECT id, phone, email, password, created_at
 FROM users
 WHERE phone = $1;
 
+-- name: GetPhoneHolder :one
+-- Whoever holds a number, an unverified claim does not keep the owner from verifying it.
+SELECT id, phone_verified_at
+FROM users
+WHERE phone = $1;
+
 -- Profile management queries
 -- name: GetUserByID :one
-SELECT id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, password, created_at, updated_at
+SELECT id, file_id, email, phone, password, created_at, updated_at, phone_verified_at, email_verified_at, pending_email, role, totp_secret, totp_enabled_at, totp_last_step, deleted_at, store_name
 FROM users
 WHERE id = $1;
 
@@ -34,25 +41,82 @@ WHERE id = $1;
 UPDATE users
 SET
     file_id = $2,
-    bank_account_name = $3,
-    bank_account_holder = $4,
-    bank_account_number = $5,
+    store_name = COALESCE(sqlc.narg('store_name'), store_name),
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at;
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at;
 
 -- name: LinkPhoneToUser :one
 UPDATE users
 SET
     phone = $2,
+    phone_verified_at = NULL,
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at;
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at;
 
--- name: LinkEmailToUser :one
+-- name: MarkPhoneVerified :one
 UPDATE users
 SET
-    email = $2,
+    phone = $2,
+    phone_verified_at = NOW(),
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at;
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at;
+
+-- name: ReleaseUnverifiedPhone :exec
+-- A number belongs to whoever verifies it, other accounts' unverified claims on it are dropped.
+UPDATE users
+SET
+    phone = NULL,
+    updated_at = NOW()
+WHERE phone = $1 AND phone_verified_at IS NULL AND id <> $2;
+
+-- name: SetPendingEmail :exec
+UPDATE users
+SET
+    pending_email = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkEmailVerified :exec
+-- Also completes an email change, the pending address becomes the email.
+UPDATE users
+SET
+    email = $2,
+    email_verified_at = NOW(),
+    pending_email = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    password = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RehashUserPassword :exec
+-- Swaps in a stronger hash of the same password, unless the password changed meanwhile.
+UPDATE users
+SET
+    password = sqlc.arg('new_password')
+WHERE id = sqlc.arg('id') AND password = sqlc.arg('old_password');
+
+-- name: GetUserRole :one
+SELECT role FROM users WHERE id = $1;
+
+-- name: PromoteToSeller :exec
+-- Admins keep their role when they start selling.
+UPDATE users
+SET
+    role = 'seller',
+    updated_at = NOW()
+WHERE id = $1 AND role = 'buyer';
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET
+    role = $2,
+    updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,62 @@
+-- name: ListProductVariants :many
+SELECT id, product_id, user_id, sku, options, qty, price, file_id, is_active, created_at, updated_at
+FROM product_variants
+WHERE product_id = $1 AND is_active
+ORDER BY id;
+
+-- name: ListProductVariantsByProductIDs :many
+-- Variants of a page of products in one round trip.
+SELECT id, product_id, user_id, sku, options, qty, price, file_id, is_active, created_at, updated_at
+FROM product_variants
+WHERE product_id = ANY(sqlc.arg('product_ids')::INT[]) AND is_active
+ORDER BY product_id, id;
+
+-- name: GetProductVariant :one
+SELECT id, product_id, user_id, sku, options, qty, price, file_id, is_active, created_at, updated_at
+FROM product_variants
+WHERE id = $1 AND product_id = $2 AND is_active;
+
+-- name: CountProductVariants :one
+-- A product with variants can only be bought through one of them.
+SELECT COUNT(*) FROM product_variants WHERE product_id = $1 AND is_active;
+
+-- name: CreateProductVariant :one
+-- user_id is the product's seller, SKUs are unique per seller through unique_variant_sku_per_user.
+INSERT INTO product_variants (product_id, user_id, sku, options, qty, price, file_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, product_id, user_id, sku, options, qty, price, file_id, is_active, created_at, updated_at;
+
+-- name: UpdateProductVariant :one
+UPDATE product_variants
+SET
+    sku = $3,
+    options = $4,
+    qty = $5,
+    price = $6,
+    file_id = $7,
+    updated_at = NOW()
+WHERE id = $1 AND product_id = $2 AND is_active
+RETURNING id, product_id, user_id, sku, options, qty, price, file_id, is_active, created_at, updated_at;
+
+-- name: DeleteProductVariant :execrows
+-- Variants may be part of purchases, deleting one only deactivates it.
+UPDATE product_variants
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE id = $1 AND product_id = $2 AND is_active;
+
+-- name: DeactivateProductVariants :exec
+-- Deleting a product deletes its variants, which frees their SKUs.
+UPDATE product_variants
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE product_id = $1 AND is_active;
+
+-- name: DeactivateUserProductVariants :exec
+UPDATE product_variants
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE user_id = $1 AND is_active;
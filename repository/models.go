@@ -6,8 +6,48 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+type ApiKey struct {
+	ID         int32        `json:"id"`
+	UserID     int32        `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	KeyHash    string       `json:"key_hash"`
+	Scopes     []string     `json:"scopes"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type Bank struct {
+	Code                   string `json:"code"`
+	Name                   string `json:"name"`
+	Kind                   string `json:"kind"`
+	AccountNumberMinLength int32  `json:"account_number_min_length"`
+	AccountNumberMaxLength int32  `json:"account_number_max_length"`
+	AccountNumberPattern   string `json:"account_number_pattern"`
+	IsActive               bool   `json:"is_active"`
+}
+
+type BankAccount struct {
+	ID                    int32          `json:"id"`
+	UserID                int32          `json:"user_id"`
+	BankAccountName       string         `json:"bank_account_name"`
+	BankAccountHolder     string         `json:"bank_account_holder"`
+	BankAccountNumber     string         `json:"bank_account_number"`
+	IsDefault             bool           `json:"is_default"`
+	IsEnabled             bool           `json:"is_enabled"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             sql.NullTime   `json:"deleted_at"`
+	BankCode              sql.NullString `json:"bank_code"`
+	BankAccountNumberBidx sql.NullString `json:"bank_account_number_bidx"`
+	StoreID               sql.NullInt32  `json:"store_id"`
+}
+
 type File struct {
 	ID              int32          `json:"id"`
 	FileUri         string         `json:"file_uri"`
@@ -16,11 +56,50 @@ type File struct {
 	UpdatedAt       sql.NullTime   `json:"updated_at"`
 }
 
+type LoginThrottle struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+	LastFailureAt time.Time    `json:"last_failure_at"`
+}
+
+type OidcState struct {
+	StateHash    string       `json:"state_hash"`
+	Provider     string       `json:"provider"`
+	CodeVerifier string       `json:"code_verifier"`
+	Nonce        string       `json:"nonce"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+	CreatedAt    sql.NullTime `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID         int32        `json:"id"`
+	UserID     int32        `json:"user_id"`
+	TokenHash  string       `json:"token_hash"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	ConsumedAt sql.NullTime `json:"consumed_at"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+}
+
 type PaymentDetail struct {
+	ID          int32         `json:"id"`
+	PurchaseID  int32         `json:"purchase_id"`
+	UserID      sql.NullInt32 `json:"user_id"`
+	FileID      sql.NullInt32 `json:"file_id"`
+	ConfirmedAt sql.NullTime  `json:"confirmed_at"`
+	ConfirmedBy sql.NullInt32 `json:"confirmed_by"`
+}
+
+type PhoneOtp struct {
 	ID         int32         `json:"id"`
-	PurchaseID int32         `json:"purchase_id"`
 	UserID     sql.NullInt32 `json:"user_id"`
-	FileID     sql.NullInt32 `json:"file_id"`
+	Phone      string        `json:"phone"`
+	Purpose    string        `json:"purpose"`
+	CodeHash   string        `json:"code_hash"`
+	Attempts   int32         `json:"attempts"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	ConsumedAt sql.NullTime  `json:"consumed_at"`
+	CreatedAt  sql.NullTime  `json:"created_at"`
 }
 
 type Product struct {
@@ -34,11 +113,41 @@ type Product struct {
 	FileID    sql.NullInt32  `json:"file_id"`
 	CreatedAt sql.NullTime   `json:"created_at"`
 	UpdatedAt sql.NullTime   `json:"updated_at"`
+	IsActive  bool           `json:"is_active"`
+	StoreID   sql.NullInt32  `json:"store_id"`
 }
 
 type ProductCategory struct {
-	ProductCategoryID int32          `json:"product_category_id"`
-	Name              sql.NullString `json:"name"`
+	ProductCategoryID int32         `json:"product_category_id"`
+	Name              string        `json:"name"`
+	ParentID          sql.NullInt32 `json:"parent_id"`
+	Slug              string        `json:"slug"`
+	IsActive          bool          `json:"is_active"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+type ProductImage struct {
+	ID        int32     `json:"id"`
+	ProductID int32     `json:"product_id"`
+	FileID    int32     `json:"file_id"`
+	SortOrder int32     `json:"sort_order"`
+	IsPrimary bool      `json:"is_primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ProductVariant struct {
+	ID        int32           `json:"id"`
+	ProductID int32           `json:"product_id"`
+	UserID    int32           `json:"user_id"`
+	Sku       string          `json:"sku"`
+	Options   json.RawMessage `json:"options"`
+	Qty       int32           `json:"qty"`
+	Price     sql.NullString  `json:"price"`
+	FileID    sql.NullInt32   `json:"file_id"`
+	IsActive  bool            `json:"is_active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
 }
 
 type Purchase struct {
@@ -50,6 +159,7 @@ type Purchase struct {
 	CreatedAt           sql.NullTime   `json:"created_at"`
 	UpdatedAt           sql.NullTime   `json:"updated_at"`
 	IsPaid              sql.NullBool   `json:"is_paid"`
+	SenderContactBidx   sql.NullString `json:"sender_contact_bidx"`
 }
 
 type PurchaseItem struct {
@@ -58,17 +168,112 @@ type PurchaseItem struct {
 	ProductID  int32          `json:"product_id"`
 	Total      sql.NullString `json:"total"`
 	Qty        sql.NullInt32  `json:"qty"`
+	VariantID  sql.NullInt32  `json:"variant_id"`
+}
+
+type PurchasePaymentAccount struct {
+	PurchaseID    int32 `json:"purchase_id"`
+	BankAccountID int32 `json:"bank_account_id"`
+}
+
+type RefreshToken struct {
+	ID              int32        `json:"id"`
+	UserID          int32        `json:"user_id"`
+	TokenHash       string       `json:"token_hash"`
+	FamilyID        string       `json:"family_id"`
+	AccessJti       string       `json:"access_jti"`
+	AccessExpiresAt time.Time    `json:"access_expires_at"`
+	ExpiresAt       time.Time    `json:"expires_at"`
+	RevokedAt       sql.NullTime `json:"revoked_at"`
+	CreatedAt       sql.NullTime `json:"created_at"`
+}
+
+type RevokedToken struct {
+	Jti       string        `json:"jti"`
+	UserID    sql.NullInt32 `json:"user_id"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	RevokedAt sql.NullTime  `json:"revoked_at"`
+}
+
+type Session struct {
+	ID         int32          `json:"id"`
+	UserID     int32          `json:"user_id"`
+	TokenHash  string         `json:"token_hash"`
+	UserAgent  sql.NullString `json:"user_agent"`
+	IpAddress  sql.NullString `json:"ip_address"`
+	CreatedAt  sql.NullTime   `json:"created_at"`
+	LastSeenAt sql.NullTime   `json:"last_seen_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+}
+
+type Store struct {
+	ID        int32     `json:"id"`
+	OwnerID   int32     `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type StoreInvitation struct {
+	ID          int32          `json:"id"`
+	StoreID     int32          `json:"store_id"`
+	Email       sql.NullString `json:"email"`
+	Phone       sql.NullString `json:"phone"`
+	Permissions []string       `json:"permissions"`
+	TokenHash   string         `json:"token_hash"`
+	InvitedBy   sql.NullInt32  `json:"invited_by"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	AcceptedAt  sql.NullTime   `json:"accepted_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type StoreMember struct {
+	StoreID     int32         `json:"store_id"`
+	UserID      int32         `json:"user_id"`
+	Permissions []string      `json:"permissions"`
+	InvitedBy   sql.NullInt32 `json:"invited_by"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+type TotpRecoveryCode struct {
+	ID        int32        `json:"id"`
+	UserID    int32        `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
+type UsedLoginChallenge struct {
+	Jti       string       `json:"jti"`
+	UserID    int32        `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
 }
 
 type User struct {
-	ID                int32          `json:"id"`
-	FileID            sql.NullInt32  `json:"file_id"`
-	Email             sql.NullString `json:"email"`
-	Phone             sql.NullString `json:"phone"`
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
-	Password          string         `json:"password"`
-	CreatedAt         sql.NullTime   `json:"created_at"`
-	UpdatedAt         sql.NullTime   `json:"updated_at"`
+	ID              int32          `json:"id"`
+	FileID          sql.NullInt32  `json:"file_id"`
+	Email           sql.NullString `json:"email"`
+	Phone           sql.NullString `json:"phone"`
+	Password        string         `json:"password"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+	UpdatedAt       sql.NullTime   `json:"updated_at"`
+	PhoneVerifiedAt sql.NullTime   `json:"phone_verified_at"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	PendingEmail    sql.NullString `json:"pending_email"`
+	Role            string         `json:"role"`
+	TotpSecret      sql.NullString `json:"totp_secret"`
+	TotpEnabledAt   sql.NullTime   `json:"totp_enabled_at"`
+	TotpLastStep    sql.NullInt64  `json:"totp_last_step"`
+	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	StoreName       sql.NullString `json:"store_name"`
+}
+
+type UserIdentity struct {
+	ID        int32          `json:"id"`
+	UserID    int32          `json:"user_id"`
+	Provider  string         `json:"provider"`
+	Subject   string         `json:"subject"`
+	Email     sql.NullString `json:"email"`
+	CreatedAt sql.NullTime   `json:"created_at"`
 }
@@ -10,14 +10,62 @@ import (
 	"database/sql"
 )
 
+const countProducts = `-- name: CountProducts :one
+SELECT COUNT(*)
+FROM products p
+         JOIN product_category pc ON p.category = pc.product_category_id
+         LEFT JOIN product_search ps ON ps.product_id = p.product_id
+WHERE
+    p.is_active AND
+    ($1::INT IS NULL OR p.product_id = $1) AND
+    ($2::TEXT IS NULL OR p.sku = $2) AND
+    ($3::TEXT IS NULL OR p.category IN (
+        WITH RECURSIVE subtree AS (
+            SELECT product_category_id FROM product_category WHERE name = $3
+            UNION ALL
+            SELECT c.product_category_id
+            FROM product_category c
+                     JOIN subtree s ON c.parent_id = s.product_category_id
+        )
+        SELECT product_category_id FROM subtree
+    )) AND
+    ($4::INT IS NULL OR p.user_id = $4) AND
+    ($5::TEXT IS NULL OR
+     ps.search_vector @@ websearch_to_tsquery('simple', $5) OR
+     $5 <% p.name)
+`
+
+type CountProductsParams struct {
+	ProductID sql.NullInt32  `json:"product_id"`
+	Sku       sql.NullString `json:"sku"`
+	Category  sql.NullString `json:"category"`
+	SellerID  sql.NullInt32  `json:"seller_id"`
+	Q         sql.NullString `json:"q"`
+}
+
+// Same filters as ListProducts, for the optional total of a listing.
+func (q *Queries) CountProducts(ctx context.Context, arg CountProductsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countProducts,
+		arg.ProductID,
+		arg.Sku,
+		arg.Category,
+		arg.SellerID,
+		arg.Q,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createProduct = `-- name: CreateProduct :one
-INSERT INTO products (user_id, name, category, qty, price, sku, file_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+INSERT INTO products (user_id, store_id, name, category, qty, price, sku, file_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 `
 
 type CreateProductParams struct {
 	UserID   sql.NullInt32  `json:"user_id"`
+	StoreID  sql.NullInt32  `json:"store_id"`
 	Name     sql.NullString `json:"name"`
 	Category sql.NullInt32  `json:"category"`
 	Qty      sql.NullInt32  `json:"qty"`
@@ -29,6 +77,7 @@ type CreateProductParams struct {
 func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (Product, error) {
 	row := q.db.QueryRowContext(ctx, createProduct,
 		arg.UserID,
+		arg.StoreID,
 		arg.Name,
 		arg.Category,
 		arg.Qty,
@@ -48,29 +97,35 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 		&i.FileID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsActive,
+		&i.StoreID,
 	)
 	return i, err
 }
 
 const deleteProduct = `-- name: DeleteProduct :exec
-DELETE FROM products
-WHERE product_id = $1 AND user_id = $2
+UPDATE products
+SET
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE product_id = $1 AND store_id = $2 AND is_active
 `
 
 type DeleteProductParams struct {
 	ProductID int32         `json:"product_id"`
-	UserID    sql.NullInt32 `json:"user_id"`
+	StoreID   sql.NullInt32 `json:"store_id"`
 }
 
+// Products may be part of purchases, deleting one only deactivates it.
 func (q *Queries) DeleteProduct(ctx context.Context, arg DeleteProductParams) error {
-	_, err := q.db.ExecContext(ctx, deleteProduct, arg.ProductID, arg.UserID)
+	_, err := q.db.ExecContext(ctx, deleteProduct, arg.ProductID, arg.StoreID)
 	return err
 }
 
 const getProductByID = `-- name: GetProductByID :one
-SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE product_id = $1
+WHERE product_id = $1 AND is_active
 `
 
 func (q *Queries) GetProductByID(ctx context.Context, productID int32) (Product, error) {
@@ -87,14 +142,16 @@ func (q *Queries) GetProductByID(ctx context.Context, productID int32) (Product,
 		&i.FileID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsActive,
+		&i.StoreID,
 	)
 	return i, err
 }
 
 const getProductBySKUAndUserID = `-- name: GetProductBySKUAndUserID :one
-SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+SELECT product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE sku = $1 AND user_id = $2
+WHERE sku = $1 AND user_id = $2 AND is_active
 `
 
 type GetProductBySKUAndUserIDParams struct {
@@ -116,56 +173,103 @@ func (q *Queries) GetProductBySKUAndUserID(ctx context.Context, arg GetProductBy
 		&i.FileID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsActive,
+		&i.StoreID,
 	)
 	return i, err
 }
 
 const getProductCategoryByName = `-- name: GetProductCategoryByName :one
-SELECT product_category_id FROM product_category WHERE name = $1
+SELECT product_category_id, name, parent_id, slug, is_active, created_at, updated_at
+FROM product_category
+WHERE name = $1
 `
 
-func (q *Queries) GetProductCategoryByName(ctx context.Context, name sql.NullString) (int32, error) {
+func (q *Queries) GetProductCategoryByName(ctx context.Context, name string) (ProductCategory, error) {
 	row := q.db.QueryRowContext(ctx, getProductCategoryByName, name)
-	var product_category_id int32
-	err := row.Scan(&product_category_id)
-	return product_category_id, err
+	var i ProductCategory
+	err := row.Scan(
+		&i.ProductCategoryID,
+		&i.Name,
+		&i.ParentID,
+		&i.Slug,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
 }
 
 const listProducts = `-- name: ListProducts :many
 SELECT
     p.product_id, p.user_id, p.name, pc.name as category_name, p.qty, p.price, p.sku, p.file_id, p.created_at, p.updated_at,
-    f.file_uri, f.file_thumnail_uri
+    f.file_uri, f.file_thumnail_uri, r.relevance
 FROM products p
          JOIN product_category pc ON p.category = pc.product_category_id
          LEFT JOIN files f on p.file_id = f.id
+         LEFT JOIN product_search ps ON ps.product_id = p.product_id
+         CROSS JOIN LATERAL (
+    SELECT CAST(CASE WHEN $1::TEXT IS NULL THEN 0 ELSE
+        COALESCE(ts_rank(ps.search_vector, websearch_to_tsquery('simple', $1)), 0) +
+        word_similarity($1, p.name)
+    END AS FLOAT8) AS relevance
+) r
 WHERE
-    ($1::INT IS NULL OR p.product_id = $1) AND
-    ($2::TEXT IS NULL OR p.sku = $2) AND
-    ($3::TEXT IS NULL OR pc.name = $3)
+    p.is_active AND
+    ($2::INT IS NULL OR p.product_id = $2) AND
+    ($3::TEXT IS NULL OR p.sku = $3) AND
+    ($4::TEXT IS NULL OR p.category IN (
+        WITH RECURSIVE subtree AS (
+            SELECT product_category_id FROM product_category WHERE name = $4
+            UNION ALL
+            SELECT c.product_category_id
+            FROM product_category c
+                     JOIN subtree s ON c.parent_id = s.product_category_id
+        )
+        SELECT product_category_id FROM subtree
+    )) AND
+    ($5::INT IS NULL OR p.user_id = $5) AND
+    ($1::TEXT IS NULL OR
+     ps.search_vector @@ websearch_to_tsquery('simple', $1) OR
+     $1 <% p.name) AND
+    ($6::INT IS NULL OR
+     ($7::TEXT = 'newest' AND (p.created_at, p.product_id) < ($8::TIMESTAMPTZ, $6)) OR
+     ($7::TEXT = 'oldest' AND (p.created_at, p.product_id) > ($8::TIMESTAMPTZ, $6)) OR
+     ($7::TEXT = 'cheapest' AND (p.price, p.product_id) > ($9::DECIMAL, $6)) OR
+     ($7::TEXT = 'expensive' AND (p.price, p.product_id) < ($9::DECIMAL, $6)) OR
+     ($7::TEXT = 'relevance' AND (r.relevance, p.product_id) < ($10::FLOAT8, $6)))
 ORDER BY
-    CASE WHEN $4::TEXT = 'newest' THEN p.created_at END DESC,
-    CASE WHEN $4::TEXT = 'oldest' THEN p.created_at END ASC,
-    CASE WHEN $4::TEXT = 'cheapest' THEN p.price END ASC,
-    CASE WHEN $4::TEXT = 'expensive' THEN p.price END DESC,
-    p.created_at DESC
-    LIMIT $6
-OFFSET $5
+    CASE WHEN $7::TEXT = 'relevance' THEN r.relevance END DESC,
+    CASE WHEN $7::TEXT = 'newest' THEN p.created_at END DESC,
+    CASE WHEN $7::TEXT = 'oldest' THEN p.created_at END ASC,
+    CASE WHEN $7::TEXT = 'cheapest' THEN p.price END ASC,
+    CASE WHEN $7::TEXT = 'expensive' THEN p.price END DESC,
+    CASE WHEN $7::TEXT IN ('oldest', 'cheapest') THEN p.product_id END ASC,
+    p.product_id DESC
+    LIMIT $11
+OFFSET $12
 `
 
 type ListProductsParams struct {
-	ProductID sql.NullInt32  `json:"product_id"`
-	Sku       sql.NullString `json:"sku"`
-	Category  sql.NullString `json:"category"`
-	SortBy    sql.NullString `json:"sort_by"`
-	Offset    int32          `json:"offset"`
-	Limit     int32          `json:"limit"`
+	Q               sql.NullString  `json:"q"`
+	ProductID       sql.NullInt32   `json:"product_id"`
+	Sku             sql.NullString  `json:"sku"`
+	Category        sql.NullString  `json:"category"`
+	SellerID        sql.NullInt32   `json:"seller_id"`
+	CursorID        sql.NullInt32   `json:"cursor_id"`
+	SortBy          string          `json:"sort_by"`
+	CursorCreatedAt sql.NullTime    `json:"cursor_created_at"`
+	CursorPrice     sql.NullString  `json:"cursor_price"`
+	CursorRelevance sql.NullFloat64 `json:"cursor_relevance"`
+	Limit           int32           `json:"limit"`
+	Offset          int32           `json:"offset"`
 }
 
 type ListProductsRow struct {
 	ProductID       int32          `json:"product_id"`
 	UserID          sql.NullInt32  `json:"user_id"`
 	Name            sql.NullString `json:"name"`
-	CategoryName    sql.NullString `json:"category_name"`
+	CategoryName    string         `json:"category_name"`
 	Qty             sql.NullInt32  `json:"qty"`
 	Price           sql.NullString `json:"price"`
 	Sku             sql.NullString `json:"sku"`
@@ -174,16 +278,27 @@ type ListProductsRow struct {
 	UpdatedAt       sql.NullTime   `json:"updated_at"`
 	FileUri         sql.NullString `json:"file_uri"`
 	FileThumnailUri sql.NullString `json:"file_thumnail_uri"`
+	Relevance       float64        `json:"relevance"`
 }
 
+// A category matches its subcategories too. With q the products are matched on their
+// search document or, for typos, by trigram similarity of the name. sort_by is one of
+// newest, oldest, cheapest, expensive and relevance, the product id breaks ties so that
+// the cursor_* position of the last product of a page continues the listing where it stopped.
 func (q *Queries) ListProducts(ctx context.Context, arg ListProductsParams) ([]ListProductsRow, error) {
 	rows, err := q.db.QueryContext(ctx, listProducts,
+		arg.Q,
 		arg.ProductID,
 		arg.Sku,
 		arg.Category,
+		arg.SellerID,
+		arg.CursorID,
 		arg.SortBy,
-		arg.Offset,
+		arg.CursorCreatedAt,
+		arg.CursorPrice,
+		arg.CursorRelevance,
 		arg.Limit,
+		arg.Offset,
 	)
 	if err != nil {
 		return nil, err
@@ -205,6 +320,7 @@ func (q *Queries) ListProducts(ctx context.Context, arg ListProductsParams) ([]L
 			&i.UpdatedAt,
 			&i.FileUri,
 			&i.FileThumnailUri,
+			&i.Relevance,
 		); err != nil {
 			return nil, err
 		}
@@ -229,8 +345,8 @@ SET
     sku = $6,
     file_id = $7,
     updated_at = NOW()
-WHERE product_id = $1 AND user_id = $8
-RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+WHERE product_id = $1 AND store_id = $8 AND is_active
+RETURNING product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 `
 
 type UpdateProductParams struct {
@@ -241,7 +357,7 @@ type UpdateProductParams struct {
 	Price     sql.NullString `json:"price"`
 	Sku       sql.NullString `json:"sku"`
 	FileID    sql.NullInt32  `json:"file_id"`
-	UserID    sql.NullInt32  `json:"user_id"`
+	StoreID   sql.NullInt32  `json:"store_id"`
 }
 
 func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error) {
@@ -253,7 +369,7 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (P
 		arg.Price,
 		arg.Sku,
 		arg.FileID,
-		arg.UserID,
+		arg.StoreID,
 	)
 	var i Product
 	err := row.Scan(
@@ -267,6 +383,8 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (P
 		&i.FileID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsActive,
+		&i.StoreID,
 	)
 	return i, err
 }
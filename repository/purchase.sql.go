@@ -27,8 +27,8 @@ func (q *Queries) CreatePaymentDetail(ctx context.Context, arg CreatePaymentDeta
 }
 
 const createPurchase = `-- name: CreatePurchase :one
-INSERT INTO purchases (sender_name, sender_contact_type, sender_contact_detail, total, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
+INSERT INTO purchases (sender_name, sender_contact_type, sender_contact_detail, sender_contact_bidx, total, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
     RETURNING id, created_at
 `
 
@@ -36,6 +36,7 @@ type CreatePurchaseParams struct {
 	SenderName          sql.NullString `json:"sender_name"`
 	SenderContactType   sql.NullString `json:"sender_contact_type"`
 	SenderContactDetail sql.NullString `json:"sender_contact_detail"`
+	SenderContactBidx   sql.NullString `json:"sender_contact_bidx"`
 	Total               sql.NullInt32  `json:"total"`
 }
 
@@ -44,11 +45,13 @@ type CreatePurchaseRow struct {
 	CreatedAt sql.NullTime `json:"created_at"`
 }
 
+// sender_contact_detail is an encrypted envelope, look it up through its blind index.
 func (q *Queries) CreatePurchase(ctx context.Context, arg CreatePurchaseParams) (CreatePurchaseRow, error) {
 	row := q.db.QueryRowContext(ctx, createPurchase,
 		arg.SenderName,
 		arg.SenderContactType,
 		arg.SenderContactDetail,
+		arg.SenderContactBidx,
 		arg.Total,
 	)
 	var i CreatePurchaseRow
@@ -57,8 +60,8 @@ func (q *Queries) CreatePurchase(ctx context.Context, arg CreatePurchaseParams)
 }
 
 const createPurchaseItem = `-- name: CreatePurchaseItem :exec
-INSERT INTO purchase_item (purchase_id, product_id, qty, total)
-VALUES ($1, $2, $3, $4)
+INSERT INTO purchase_item (purchase_id, product_id, qty, total, variant_id)
+VALUES ($1, $2, $3, $4, $5)
 `
 
 type CreatePurchaseItemParams struct {
@@ -66,6 +69,7 @@ type CreatePurchaseItemParams struct {
 	ProductID  int32          `json:"product_id"`
 	Qty        sql.NullInt32  `json:"qty"`
 	Total      sql.NullString `json:"total"`
+	VariantID  sql.NullInt32  `json:"variant_id"`
 }
 
 func (q *Queries) CreatePurchaseItem(ctx context.Context, arg CreatePurchaseItemParams) error {
@@ -74,27 +78,43 @@ func (q *Queries) CreatePurchaseItem(ctx context.Context, arg CreatePurchaseItem
 		arg.ProductID,
 		arg.Qty,
 		arg.Total,
+		arg.VariantID,
 	)
 	return err
 }
 
+const createPurchasePaymentAccount = `-- name: CreatePurchasePaymentAccount :exec
+INSERT INTO purchase_payment_accounts (purchase_id, bank_account_id)
+VALUES ($1, $2)
+`
+
+type CreatePurchasePaymentAccountParams struct {
+	PurchaseID    int32 `json:"purchase_id"`
+	BankAccountID int32 `json:"bank_account_id"`
+}
+
+func (q *Queries) CreatePurchasePaymentAccount(ctx context.Context, arg CreatePurchasePaymentAccountParams) error {
+	_, err := q.db.ExecContext(ctx, createPurchasePaymentAccount, arg.PurchaseID, arg.BankAccountID)
+	return err
+}
+
 const getProductCategoryByID = `-- name: GetProductCategoryByID :one
 SELECT name FROM product_category WHERE product_category_id = $1
 `
 
 // New query to fetch a category name by its ID.
-func (q *Queries) GetProductCategoryByID(ctx context.Context, productCategoryID int32) (sql.NullString, error) {
+func (q *Queries) GetProductCategoryByID(ctx context.Context, productCategoryID int32) (string, error) {
 	row := q.db.QueryRowContext(ctx, getProductCategoryByID, productCategoryID)
-	var name sql.NullString
+	var name string
 	err := row.Scan(&name)
 	return name, err
 }
 
 const getProductForUpdate = `-- name: GetProductForUpdate :one
 SELECT
-    product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at
+    product_id, user_id, name, category, qty, price, sku, file_id, created_at, updated_at, is_active, store_id
 FROM products
-WHERE product_id = $1
+WHERE product_id = $1 AND is_active
 `
 
 // This query now only fetches from the products table, without the JOIN.
@@ -112,6 +132,8 @@ func (q *Queries) GetProductForUpdate(ctx context.Context, productID int32) (Pro
 		&i.FileID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsActive,
+		&i.StoreID,
 	)
 	return i, err
 }
@@ -150,7 +172,7 @@ func (q *Queries) GetPurchaseByID(ctx context.Context, id int32) (GetPurchaseByI
 }
 
 const getPurchaseItemsByPurchaseID = `-- name: GetPurchaseItemsByPurchaseID :many
-SELECT pi.id, pi.purchase_id, pi.product_id, pi.qty, pi.total, p.user_id
+SELECT pi.id, pi.purchase_id, pi.product_id, pi.qty, pi.total, pi.variant_id, p.user_id
 FROM purchase_item pi
 JOIN products p ON pi.product_id = p.product_id
 WHERE pi.purchase_id = $1
@@ -162,6 +184,7 @@ type GetPurchaseItemsByPurchaseIDRow struct {
 	ProductID  int32          `json:"product_id"`
 	Qty        sql.NullInt32  `json:"qty"`
 	Total      sql.NullString `json:"total"`
+	VariantID  sql.NullInt32  `json:"variant_id"`
 	UserID     sql.NullInt32  `json:"user_id"`
 }
 
@@ -180,6 +203,7 @@ func (q *Queries) GetPurchaseItemsByPurchaseID(ctx context.Context, purchaseID i
 			&i.ProductID,
 			&i.Qty,
 			&i.Total,
+			&i.VariantID,
 			&i.UserID,
 		); err != nil {
 			return nil, err
@@ -195,23 +219,126 @@ func (q *Queries) GetPurchaseItemsByPurchaseID(ctx context.Context, purchaseID i
 	return items, nil
 }
 
-const getSellerBankDetailsByUserID = `-- name: GetSellerBankDetailsByUserID :one
-SELECT bank_account_name, bank_account_holder, bank_account_number
-FROM users
+const listPurchasesBySenderContact = `-- name: ListPurchasesBySenderContact :many
+SELECT id, sender_name, sender_contact_type, sender_contact_detail, total, is_paid, created_at
+FROM purchases
+WHERE sender_contact_bidx IN ($1, $2)
+ORDER BY id
+`
+
+type ListPurchasesBySenderContactParams struct {
+	EmailBidx sql.NullString `json:"email_bidx"`
+	PhoneBidx sql.NullString `json:"phone_bidx"`
+}
+
+type ListPurchasesBySenderContactRow struct {
+	ID                  int32          `json:"id"`
+	SenderName          sql.NullString `json:"sender_name"`
+	SenderContactType   sql.NullString `json:"sender_contact_type"`
+	SenderContactDetail sql.NullString `json:"sender_contact_detail"`
+	Total               sql.NullInt32  `json:"total"`
+	IsPaid              sql.NullBool   `json:"is_paid"`
+	CreatedAt           sql.NullTime   `json:"created_at"`
+}
+
+// Purchases a buyer placed with one of their verified contacts.
+func (q *Queries) ListPurchasesBySenderContact(ctx context.Context, arg ListPurchasesBySenderContactParams) ([]ListPurchasesBySenderContactRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPurchasesBySenderContact, arg.EmailBidx, arg.PhoneBidx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPurchasesBySenderContactRow
+	for rows.Next() {
+		var i ListPurchasesBySenderContactRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderName,
+			&i.SenderContactType,
+			&i.SenderContactDetail,
+			&i.Total,
+			&i.IsPaid,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurchasesForReencryption = `-- name: ListPurchasesForReencryption :many
+SELECT id, sender_contact_type, sender_contact_detail, sender_contact_bidx
+FROM purchases
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListPurchasesForReencryptionParams struct {
+	ID    int32 `json:"id"`
+	Limit int32 `json:"limit"`
+}
+
+type ListPurchasesForReencryptionRow struct {
+	ID                  int32          `json:"id"`
+	SenderContactType   sql.NullString `json:"sender_contact_type"`
+	SenderContactDetail sql.NullString `json:"sender_contact_detail"`
+	SenderContactBidx   sql.NullString `json:"sender_contact_bidx"`
+}
+
+// Walks every purchase in id order for cmd/reencrypt.
+func (q *Queries) ListPurchasesForReencryption(ctx context.Context, arg ListPurchasesForReencryptionParams) ([]ListPurchasesForReencryptionRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPurchasesForReencryption, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPurchasesForReencryptionRow
+	for rows.Next() {
+		var i ListPurchasesForReencryptionRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderContactType,
+			&i.SenderContactDetail,
+			&i.SenderContactBidx,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reencryptPurchaseContact = `-- name: ReencryptPurchaseContact :exec
+UPDATE purchases
+SET
+    sender_contact_detail = $2,
+    sender_contact_bidx = $3
 WHERE id = $1
 `
 
-type GetSellerBankDetailsByUserIDRow struct {
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
+type ReencryptPurchaseContactParams struct {
+	ID                  int32          `json:"id"`
+	SenderContactDetail sql.NullString `json:"sender_contact_detail"`
+	SenderContactBidx   sql.NullString `json:"sender_contact_bidx"`
 }
 
-func (q *Queries) GetSellerBankDetailsByUserID(ctx context.Context, id int32) (GetSellerBankDetailsByUserIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getSellerBankDetailsByUserID, id)
-	var i GetSellerBankDetailsByUserIDRow
-	err := row.Scan(&i.BankAccountName, &i.BankAccountHolder, &i.BankAccountNumber)
-	return i, err
+func (q *Queries) ReencryptPurchaseContact(ctx context.Context, arg ReencryptPurchaseContactParams) error {
+	_, err := q.db.ExecContext(ctx, reencryptPurchaseContact, arg.ID, arg.SenderContactDetail, arg.SenderContactBidx)
+	return err
 }
 
 const updateProductQuantity = `-- name: UpdateProductQuantity :exec
@@ -230,6 +357,22 @@ func (q *Queries) UpdateProductQuantity(ctx context.Context, arg UpdateProductQu
 	return err
 }
 
+const updateProductVariantQuantity = `-- name: UpdateProductVariantQuantity :exec
+UPDATE product_variants
+SET qty = qty - $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateProductVariantQuantityParams struct {
+	ID  int32 `json:"id"`
+	Qty int32 `json:"qty"`
+}
+
+func (q *Queries) UpdateProductVariantQuantity(ctx context.Context, arg UpdateProductVariantQuantityParams) error {
+	_, err := q.db.ExecContext(ctx, updateProductVariantQuantity, arg.ID, arg.Qty)
+	return err
+}
+
 const updatePurchasePaymentStatus = `-- name: UpdatePurchasePaymentStatus :exec
 UPDATE purchases 
 SET is_paid = TRUE, updated_at = NOW()
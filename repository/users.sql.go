@@ -97,10 +97,29 @@ func (q *Queries) CreateUserWithPhone(ctx context.Context, arg CreateUserWithPho
 	return i, err
 }
 
+const getPhoneHolder = `-- name: GetPhoneHolder :one
+SELECT id, phone_verified_at
+FROM users
+WHERE phone = $1
+`
+
+type GetPhoneHolderRow struct {
+	ID              int32        `json:"id"`
+	PhoneVerifiedAt sql.NullTime `json:"phone_verified_at"`
+}
+
+// Whoever holds a number, an unverified claim does not keep the owner from verifying it.
+func (q *Queries) GetPhoneHolder(ctx context.Context, phone sql.NullString) (GetPhoneHolderRow, error) {
+	row := q.db.QueryRowContext(ctx, getPhoneHolder, phone)
+	var i GetPhoneHolderRow
+	err := row.Scan(&i.ID, &i.PhoneVerifiedAt)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT id, email, phone, password, created_at
 FROM users
-WHERE email = $1
+WHERE lower(email) = lower($1)
 `
 
 type GetUserByEmailRow struct {
@@ -111,6 +130,7 @@ type GetUserByEmailRow struct {
 	CreatedAt sql.NullTime   `json:"created_at"`
 }
 
+// Emails are matched case-insensitively, older accounts may be stored in mixed case.
 func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (GetUserByEmailRow, error) {
 	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
 	var i GetUserByEmailRow
@@ -125,7 +145,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (Get
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, password, created_at, updated_at
+SELECT id, file_id, email, phone, password, created_at, updated_at, phone_verified_at, email_verified_at, pending_email, role, totp_secret, totp_enabled_at, totp_last_step, deleted_at, store_name
 FROM users
 WHERE id = $1
 `
@@ -139,12 +159,18 @@ func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
 		&i.FileID,
 		&i.Email,
 		&i.Phone,
-		&i.BankAccountName,
-		&i.BankAccountHolder,
-		&i.BankAccountNumber,
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.PhoneVerifiedAt,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.DeletedAt,
+		&i.StoreName,
 	)
 	return i, err
 }
@@ -176,141 +202,262 @@ func (q *Queries) GetUserByPhone(ctx context.Context, phone sql.NullString) (Get
 	return i, err
 }
 
-const linkEmailToUser = `-- name: LinkEmailToUser :one
+const getUserRole = `-- name: GetUserRole :one
+SELECT role FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserRole(ctx context.Context, id int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserRole, id)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const linkPhoneToUser = `-- name: LinkPhoneToUser :one
 UPDATE users
 SET
-    email = $2,
+    phone = $2,
+    phone_verified_at = NULL,
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at
 `
 
-type LinkEmailToUserParams struct {
+type LinkPhoneToUserParams struct {
 	ID    int32          `json:"id"`
-	Email sql.NullString `json:"email"`
+	Phone sql.NullString `json:"phone"`
 }
 
-type LinkEmailToUserRow struct {
-	ID                int32          `json:"id"`
-	FileID            sql.NullInt32  `json:"file_id"`
-	Email             sql.NullString `json:"email"`
-	Phone             sql.NullString `json:"phone"`
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
-	CreatedAt         sql.NullTime   `json:"created_at"`
-	UpdatedAt         sql.NullTime   `json:"updated_at"`
+type LinkPhoneToUserRow struct {
+	ID        int32          `json:"id"`
+	FileID    sql.NullInt32  `json:"file_id"`
+	Email     sql.NullString `json:"email"`
+	Phone     sql.NullString `json:"phone"`
+	StoreName sql.NullString `json:"store_name"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+	UpdatedAt sql.NullTime   `json:"updated_at"`
 }
 
-func (q *Queries) LinkEmailToUser(ctx context.Context, arg LinkEmailToUserParams) (LinkEmailToUserRow, error) {
-	row := q.db.QueryRowContext(ctx, linkEmailToUser, arg.ID, arg.Email)
-	var i LinkEmailToUserRow
+func (q *Queries) LinkPhoneToUser(ctx context.Context, arg LinkPhoneToUserParams) (LinkPhoneToUserRow, error) {
+	row := q.db.QueryRowContext(ctx, linkPhoneToUser, arg.ID, arg.Phone)
+	var i LinkPhoneToUserRow
 	err := row.Scan(
 		&i.ID,
 		&i.FileID,
 		&i.Email,
 		&i.Phone,
-		&i.BankAccountName,
-		&i.BankAccountHolder,
-		&i.BankAccountNumber,
+		&i.StoreName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const linkPhoneToUser = `-- name: LinkPhoneToUser :one
+const markEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users
+SET
+    email = $2,
+    email_verified_at = NOW(),
+    pending_email = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    int32          `json:"id"`
+	Email sql.NullString `json:"email"`
+}
+
+// Also completes an email change, the pending address becomes the email.
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) error {
+	_, err := q.db.ExecContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	return err
+}
+
+const markPhoneVerified = `-- name: MarkPhoneVerified :one
 UPDATE users
 SET
     phone = $2,
+    phone_verified_at = NOW(),
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at
 `
 
-type LinkPhoneToUserParams struct {
+type MarkPhoneVerifiedParams struct {
 	ID    int32          `json:"id"`
 	Phone sql.NullString `json:"phone"`
 }
 
-type LinkPhoneToUserRow struct {
-	ID                int32          `json:"id"`
-	FileID            sql.NullInt32  `json:"file_id"`
-	Email             sql.NullString `json:"email"`
-	Phone             sql.NullString `json:"phone"`
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
-	CreatedAt         sql.NullTime   `json:"created_at"`
-	UpdatedAt         sql.NullTime   `json:"updated_at"`
+type MarkPhoneVerifiedRow struct {
+	ID        int32          `json:"id"`
+	FileID    sql.NullInt32  `json:"file_id"`
+	Email     sql.NullString `json:"email"`
+	Phone     sql.NullString `json:"phone"`
+	StoreName sql.NullString `json:"store_name"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+	UpdatedAt sql.NullTime   `json:"updated_at"`
 }
 
-func (q *Queries) LinkPhoneToUser(ctx context.Context, arg LinkPhoneToUserParams) (LinkPhoneToUserRow, error) {
-	row := q.db.QueryRowContext(ctx, linkPhoneToUser, arg.ID, arg.Phone)
-	var i LinkPhoneToUserRow
+func (q *Queries) MarkPhoneVerified(ctx context.Context, arg MarkPhoneVerifiedParams) (MarkPhoneVerifiedRow, error) {
+	row := q.db.QueryRowContext(ctx, markPhoneVerified, arg.ID, arg.Phone)
+	var i MarkPhoneVerifiedRow
 	err := row.Scan(
 		&i.ID,
 		&i.FileID,
 		&i.Email,
 		&i.Phone,
-		&i.BankAccountName,
-		&i.BankAccountHolder,
-		&i.BankAccountNumber,
+		&i.StoreName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const promoteToSeller = `-- name: PromoteToSeller :exec
+UPDATE users
+SET
+    role = 'seller',
+    updated_at = NOW()
+WHERE id = $1 AND role = 'buyer'
+`
+
+// Admins keep their role when they start selling.
+func (q *Queries) PromoteToSeller(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, promoteToSeller, id)
+	return err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET
+    password = $1
+WHERE id = $2 AND password = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewPassword string `json:"new_password"`
+	ID          int32  `json:"id"`
+	OldPassword string `json:"old_password"`
+}
+
+// Swaps in a stronger hash of the same password, unless the password changed meanwhile.
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewPassword, arg.ID, arg.OldPassword)
+	return err
+}
+
+const releaseUnverifiedPhone = `-- name: ReleaseUnverifiedPhone :exec
+UPDATE users
+SET
+    phone = NULL,
+    updated_at = NOW()
+WHERE phone = $1 AND phone_verified_at IS NULL AND id <> $2
+`
+
+type ReleaseUnverifiedPhoneParams struct {
+	Phone sql.NullString `json:"phone"`
+	ID    int32          `json:"id"`
+}
+
+// A number belongs to whoever verifies it, other accounts' unverified claims on it are dropped.
+func (q *Queries) ReleaseUnverifiedPhone(ctx context.Context, arg ReleaseUnverifiedPhoneParams) error {
+	_, err := q.db.ExecContext(ctx, releaseUnverifiedPhone, arg.Phone, arg.ID)
+	return err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :exec
+UPDATE users
+SET
+    pending_email = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetPendingEmailParams struct {
+	ID           int32          `json:"id"`
+	PendingEmail sql.NullString `json:"pending_email"`
+}
+
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setPendingEmail, arg.ID, arg.PendingEmail)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :execrows
+UPDATE users
+SET
+    role = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserRoleParams struct {
+	ID   int32  `json:"id"`
+	Role string `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.ID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET
+    password = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID       int32  `json:"id"`
+	Password string `json:"password"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
+}
+
 const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users
 SET
     file_id = $2,
-    bank_account_name = $3,
-    bank_account_holder = $4,
-    bank_account_number = $5,
+    store_name = COALESCE($3, store_name),
     updated_at = NOW()
 WHERE id = $1
-    RETURNING id, file_id, email, phone, bank_account_name, bank_account_holder, bank_account_number, created_at, updated_at
+    RETURNING id, file_id, email, phone, store_name, created_at, updated_at
 `
 
 type UpdateUserProfileParams struct {
-	ID                int32          `json:"id"`
-	FileID            sql.NullInt32  `json:"file_id"`
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
+	ID        int32          `json:"id"`
+	FileID    sql.NullInt32  `json:"file_id"`
+	StoreName sql.NullString `json:"store_name"`
 }
 
 type UpdateUserProfileRow struct {
-	ID                int32          `json:"id"`
-	FileID            sql.NullInt32  `json:"file_id"`
-	Email             sql.NullString `json:"email"`
-	Phone             sql.NullString `json:"phone"`
-	BankAccountName   sql.NullString `json:"bank_account_name"`
-	BankAccountHolder sql.NullString `json:"bank_account_holder"`
-	BankAccountNumber sql.NullString `json:"bank_account_number"`
-	CreatedAt         sql.NullTime   `json:"created_at"`
-	UpdatedAt         sql.NullTime   `json:"updated_at"`
+	ID        int32          `json:"id"`
+	FileID    sql.NullInt32  `json:"file_id"`
+	Email     sql.NullString `json:"email"`
+	Phone     sql.NullString `json:"phone"`
+	StoreName sql.NullString `json:"store_name"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+	UpdatedAt sql.NullTime   `json:"updated_at"`
 }
 
 func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (UpdateUserProfileRow, error) {
-	row := q.db.QueryRowContext(ctx, updateUserProfile,
-		arg.ID,
-		arg.FileID,
-		arg.BankAccountName,
-		arg.BankAccountHolder,
-		arg.BankAccountNumber,
-	)
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.ID, arg.FileID, arg.StoreName)
 	var i UpdateUserProfileRow
 	err := row.Scan(
 		&i.ID,
 		&i.FileID,
 		&i.Email,
 		&i.Phone,
-		&i.BankAccountName,
-		&i.BankAccountHolder,
-		&i.BankAccountNumber,
+		&i.StoreName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
@@ -0,0 +1,442 @@
+package routes
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+}
+
+func NewAccountHandler(queries *repository.Queries, db *sql.DB) *AccountHandler {
+	return &AccountHandler{Queries: queries, DB: db}
+}
+
+// Request structs
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Required once two-factor authentication is enabled
+	TotpCode string `json:"totpCode"`
+}
+
+// Export structs, one JSON file each in the archive
+type ExportProfile struct {
+	UserID           int32                 `json:"userId"`
+	Email            string                `json:"email"`
+	Phone            string                `json:"phone"`
+	StoreName        string                `json:"storeName"`
+	PendingEmail     string                `json:"pendingEmail,omitempty"`
+	EmailVerifiedAt  *time.Time            `json:"emailVerifiedAt"`
+	PhoneVerifiedAt  *time.Time            `json:"phoneVerifiedAt"`
+	Role             string                `json:"role"`
+	FileID           string                `json:"fileId"`
+	BankAccounts     []BankAccountResponse `json:"bankAccounts"`
+	TwoFactorEnabled bool                  `json:"twoFactorEnabled"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
+}
+
+type ExportProduct struct {
+	ProductID string    `json:"productId"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Qty       int32     `json:"qty"`
+	Price     string    `json:"price"`
+	Sku       string    `json:"sku"`
+	FileID    string    `json:"fileId"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ExportFile struct {
+	FileID           string    `json:"fileId"`
+	FileURI          string    `json:"fileUri"`
+	FileThumbnailURI string    `json:"fileThumbnailUri"`
+	Path             string    `json:"path,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type ExportPurchaseItem struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	Sku       string `json:"sku"`
+	Qty       int32  `json:"qty"`
+	Total     string `json:"total"`
+}
+
+// ExportPurchase only lists the user's own products of a purchase. The buyer's
+// contact details are the buyer's personal data and are left out.
+type ExportPurchase struct {
+	PurchaseID string               `json:"purchaseId"`
+	IsPaid     bool                 `json:"isPaid"`
+	CreatedAt  time.Time            `json:"createdAt"`
+	Items      []ExportPurchaseItem `json:"items"`
+}
+
+// ExportOrder is a purchase the user placed as a buyer with their verified email or phone
+type ExportOrder struct {
+	PurchaseID    string    `json:"purchaseId"`
+	SenderName    string    `json:"senderName"`
+	ContactType   string    `json:"senderContactType"`
+	ContactDetail string    `json:"senderContactDetail"`
+	Total         int32     `json:"total"`
+	IsPaid        bool      `json:"isPaid"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func nullTimeToPointer(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// GET /v1/user/export
+func (h *AccountHandler) ExportData(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Everything is loaded before the first byte is written, errors can still be reported as JSON
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	bankAccounts, err := h.Queries.ListBankAccountsByUserID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	ownerID := sql.NullInt32{Int32: userID, Valid: true}
+	productRows, err := h.Queries.ListProductsByUserID(c, ownerID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list products for export")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	purchaseRows, err := h.Queries.ListPurchaseItemsBySellerID(c, ownerID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list purchases for export")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	profile := ExportProfile{
+		UserID:           user.ID,
+		Email:            utils.NullStringToString(user.Email),
+		Phone:            utils.NullStringToString(user.Phone),
+		StoreName:        utils.NullStringToString(user.StoreName),
+		PendingEmail:     utils.NullStringToString(user.PendingEmail),
+		EmailVerifiedAt:  nullTimeToPointer(user.EmailVerifiedAt),
+		PhoneVerifiedAt:  nullTimeToPointer(user.PhoneVerifiedAt),
+		Role:             user.Role,
+		FileID:           utils.NullInt32ToString(user.FileID),
+		BankAccounts:     make([]BankAccountResponse, 0, len(bankAccounts)),
+		TwoFactorEnabled: user.TotpEnabledAt.Valid,
+		CreatedAt:        user.CreatedAt.Time,
+		UpdatedAt:        user.UpdatedAt.Time,
+	}
+	for _, account := range bankAccounts {
+		item, err := toBankAccountResponse(account)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to decrypt bank account for export")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		profile.BankAccounts = append(profile.BankAccounts, item)
+	}
+
+	orders, err := h.exportOrders(c, user)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list orders for export")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Files are not owned by anyone, the user's files are the ones their profile and products use
+	fileIDs := []sql.NullInt32{user.FileID}
+	products := make([]ExportProduct, 0, len(productRows))
+	for _, p := range productRows {
+		products = append(products, ExportProduct{
+			ProductID: fmt.Sprintf("%d", p.ProductID),
+			Name:      utils.NullStringToString(p.Name),
+			Category:  utils.NullStringToString(p.CategoryName),
+			Qty:       p.Qty.Int32,
+			Price:     utils.NullStringToString(p.Price),
+			Sku:       utils.NullStringToString(p.Sku),
+			FileID:    utils.NullInt32ToString(p.FileID),
+			IsActive:  p.IsActive,
+			CreatedAt: p.CreatedAt.Time,
+			UpdatedAt: p.UpdatedAt.Time,
+		})
+		fileIDs = append(fileIDs, p.FileID)
+	}
+
+	files := make([]ExportFile, 0, len(fileIDs))
+	seen := make(map[int32]bool)
+	for _, id := range fileIDs {
+		if !id.Valid || seen[id.Int32] {
+			continue
+		}
+		seen[id.Int32] = true
+		file, err := h.Queries.GetFileByID(c, id.Int32)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		files = append(files, ExportFile{
+			FileID:           utils.NullInt32ToString(id),
+			FileURI:          file.FileUri,
+			FileThumbnailURI: utils.NullStringToString(file.FileThumnailUri),
+			CreatedAt:        file.CreatedAt.Time,
+		})
+	}
+
+	purchases := make([]ExportPurchase, 0)
+	for _, row := range purchaseRows {
+		purchaseID := fmt.Sprintf("%d", row.PurchaseID)
+		if len(purchases) == 0 || purchases[len(purchases)-1].PurchaseID != purchaseID {
+			purchases = append(purchases, ExportPurchase{
+				PurchaseID: purchaseID,
+				IsPaid:     row.IsPaid.Bool,
+				CreatedAt:  row.CreatedAt.Time,
+			})
+		}
+		last := &purchases[len(purchases)-1]
+		last.Items = append(last.Items, ExportPurchaseItem{
+			ProductID: fmt.Sprintf("%d", row.ProductID),
+			Name:      utils.NullStringToString(row.Name),
+			Sku:       utils.NullStringToString(row.Sku),
+			Qty:       row.Qty.Int32,
+			Total:     utils.NullStringToString(row.Total),
+		})
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="tutuplapak-export.zip"`)
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for i := range files {
+		// Uploads stored on this server go into the archive next to their metadata
+		name := path.Base(files[i].FileURI)
+		if err := addFileToZip(archive, "files/"+name, filepath.Join("uploads", name)); err != nil {
+			if !os.IsNotExist(err) {
+				utils.Logger.Error().Err(err).Str("file", name).Msg("Failed to add file to export")
+			}
+			continue
+		}
+		files[i].Path = "files/" + name
+	}
+	entries := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"products.json", products},
+		{"files.json", files},
+		{"purchases.json", purchases},
+		{"orders.json", orders},
+	}
+	for _, entry := range entries {
+		if err := addJSONToZip(archive, entry.name, entry.data); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to write data export")
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to finish data export")
+	}
+}
+
+// exportOrders finds purchases through the blind index of the sender contact,
+// only contacts the user has verified are trusted to be theirs
+func (h *AccountHandler) exportOrders(c *gin.Context, user repository.User) ([]ExportOrder, error) {
+	var params repository.ListPurchasesBySenderContactParams
+	if user.EmailVerifiedAt.Valid && user.Email.Valid {
+		params.EmailBidx = sql.NullString{String: utils.DataKeys.SenderContactIndex("email", user.Email.String), Valid: true}
+	}
+	if user.PhoneVerifiedAt.Valid && user.Phone.Valid {
+		params.PhoneBidx = sql.NullString{String: utils.DataKeys.SenderContactIndex("phone", user.Phone.String), Valid: true}
+	}
+	orders := make([]ExportOrder, 0)
+	if !params.EmailBidx.Valid && !params.PhoneBidx.Valid {
+		return orders, nil
+	}
+
+	rows, err := h.Queries.ListPurchasesBySenderContact(c, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		detail, err := utils.DataKeys.Decrypt(utils.FieldSenderContactDetail, row.SenderContactDetail.String)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, ExportOrder{
+			PurchaseID:    fmt.Sprintf("%d", row.ID),
+			SenderName:    utils.NullStringToString(row.SenderName),
+			ContactType:   utils.NullStringToString(row.SenderContactType),
+			ContactDetail: detail,
+			Total:         row.Total.Int32,
+			IsPaid:        row.IsPaid.Bool,
+			CreatedAt:     row.CreatedAt.Time,
+		})
+	}
+	return orders, nil
+}
+
+func addJSONToZip(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func addFileToZip(archive *zip.Writer, name, diskPath string) error {
+	f, err := os.Open(diskPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// DELETE /v1/user
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if ok, _, err := utils.Passwords.Verify(user.Password, req.Password); !ok || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if !requireFreshTOTP(c, h.Queries, userID, req.TotpCode) {
+		return
+	}
+
+	unusablePassword, err := utils.Passwords.Hash(utils.GenerateToken())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	// The user row and their products stay so purchases keep pointing at them
+	deleted, err := qtx.AnonymizeUser(c, repository.AnonymizeUserParams{
+		ID:       userID,
+		Password: unusablePassword,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to anonymize user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user is not found"})
+		return
+	}
+	if err := qtx.DeactivateUserProducts(c, sql.NullInt32{Int32: userID, Valid: true}); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to deactivate products of deleted account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeactivateUserProductVariants(c, userID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to deactivate product variants of deleted account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// Purchases keep pointing at the payout accounts they showed
+	if err := qtx.DeleteUserBankAccounts(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// Staff lose access to the store and the account leaves the stores it worked in
+	if err := qtx.DeleteUserStoreMemberships(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeleteOwnerStoreInvitations(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// Nothing may sign in as the account anymore
+	if err := qtx.RevokeUserAPIKeys(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeleteUserIdentities(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.InvalidateUserPasswordResetTokens(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if err := revokeAllUserTokens(c, h.Queries, userID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to revoke sessions of deleted account")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
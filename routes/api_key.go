@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	Queries *repository.Queries
+}
+
+func NewAPIKeyHandler(queries *repository.Queries) *APIKeyHandler {
+	return &APIKeyHandler{Queries: queries}
+}
+
+// Request struct
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,required"`
+}
+
+// Response structs
+type APIKeyResponse struct {
+	APIKeyID   string     `json:"apiKeyId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreateAPIKeyResponse is the only response that ever contains the key itself
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+func toAPIKeyResponse(apiKey repository.ApiKey) APIKeyResponse {
+	response := APIKeyResponse{
+		APIKeyID:  strconv.FormatInt(int64(apiKey.ID), 10),
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt.Time,
+	}
+	if apiKey.LastUsedAt.Valid {
+		lastUsedAt := apiKey.LastUsedAt.Time
+		response.LastUsedAt = &lastUsedAt
+	}
+	return response
+}
+
+// POST /v1/user/api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	// Validate scopes, duplicates are dropped
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !utils.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key, prefix := utils.GenerateAPIKey()
+	apiKey, err := h.Queries.CreateAPIKey(c, repository.CreateAPIKeyParams{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: utils.HashToken(key),
+		Scopes:  scopes,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to create API key")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(apiKey),
+		Key:            key,
+	})
+}
+
+// GET /v1/user/api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	apiKeys, err := h.Queries.ListAPIKeysByUserID(c, userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list API keys")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		response = append(response, toAPIKeyResponse(apiKey))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /v1/user/api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	apiKeyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "apiKeyId is not found"})
+		return
+	}
+
+	revoked, err := h.Queries.RevokeAPIKey(c, repository.RevokeAPIKeyParams{
+		ID:     int32(apiKeyID),
+		UserID: userID,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to revoke API key")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "apiKeyId is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -2,21 +2,24 @@ package routes
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
 
+	"tutuplapak-go/provider"
 	"tutuplapak-go/repository"
 	"tutuplapak-go/utils"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
 	Queries *repository.Queries
+	Mailer  provider.Mailer
 }
 
-func NewAuthHandler(queries *repository.Queries) *AuthHandler {
-	return &AuthHandler{Queries: queries}
+func NewAuthHandler(queries *repository.Queries, mailer provider.Mailer) *AuthHandler {
+	return &AuthHandler{Queries: queries, Mailer: mailer}
 }
 
 // Request structs
@@ -42,9 +45,10 @@ type LoginPhoneRequest struct {
 
 // Response struct
 type AuthResponse struct {
-	Email string `json:"email"`
-	Phone string `json:"phone"`
-	Token string `json:"token"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
 }
 
 // Email Registration - POST /v1/register/email
@@ -55,6 +59,11 @@ func (h *AuthHandler) RegisterEmail(c *gin.Context) {
 		return
 	}
 
+	if utils.IsCommonPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too common"})
+		return
+	}
+
 	// Check if user already exists
 	_, err := h.Queries.GetUserByEmail(c, sql.NullString{String: req.Email, Valid: true})
 	if err == nil {
@@ -63,7 +72,7 @@ func (h *AuthHandler) RegisterEmail(c *gin.Context) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := utils.Passwords.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
@@ -72,24 +81,36 @@ func (h *AuthHandler) RegisterEmail(c *gin.Context) {
 	// Create user
 	user, err := h.Queries.CreateUserWithEmail(c, repository.CreateUserWithEmailParams{
 		Email:    sql.NullString{String: req.Email, Valid: true},
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		Phone:    sql.NullString{String: "", Valid: false}, // Empty phone initially
 	})
 	if err != nil {
+		// A concurrent registration took the address, possibly in another case
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
 
-	// Generate token
-	token, err := utils.GenerateJWTToken(uint(user.ID))
+	// The account works right away, the address is verified through the emailed link
+	if err := sendEmailLink(c, h.Mailer, user.ID, req.Email, utils.LinkPurposeVerifyEmail); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to send verification email")
+	}
+
+	// Start a new session with access and refresh tokens
+	token, refreshToken, err := startSession(c, h.Queries, user.ID)
 	if err != nil {
-		utils.Logger.Error().Err(err)
-		utils.Logger.Error().Msg("failed to create token")
+		utils.Logger.Error().Err(err).Msg("failed to create token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
 	}
 	c.JSON(http.StatusCreated, AuthResponse{
-		Email: user.Email.String,
-		Phone: "", // Empty string if first registering
-		Token: token,
+		Email:        user.Email.String,
+		Phone:        "", // Empty string if first registering
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -101,11 +122,18 @@ func (h *AuthHandler) RegisterPhone(c *gin.Context) {
 		return
 	}
 
-	// Validate phone format
-	if !utils.ValidatePhone(req.Phone) {
+	// Validate phone format, phones are stored and looked up in E.164
+	phone, ok := utils.NormalizePhone(req.Phone)
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
 		return
 	}
+	req.Phone = phone
+
+	if utils.IsCommonPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too common"})
+		return
+	}
 
 	// Check if phone already exists
 	_, err := h.Queries.GetUserByPhone(c, sql.NullString{String: req.Phone, Valid: true})
@@ -115,7 +143,7 @@ func (h *AuthHandler) RegisterPhone(c *gin.Context) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := utils.Passwords.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
@@ -124,7 +152,7 @@ func (h *AuthHandler) RegisterPhone(c *gin.Context) {
 	// Create user with phone
 	user, err := h.Queries.CreateUserWithPhone(c, repository.CreateUserWithPhoneParams{
 		Phone:    sql.NullString{String: req.Phone, Valid: true},
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		Email:    sql.NullString{String: "", Valid: false}, // Empty email initially
 	})
 	if err != nil {
@@ -132,16 +160,18 @@ func (h *AuthHandler) RegisterPhone(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := utils.GenerateJWTToken(uint(user.ID))
+	// Start a new session with access and refresh tokens
+	token, refreshToken, err := startSession(c, h.Queries, user.ID)
 	if err != nil {
-		utils.Logger.Error().Err(err)
-		utils.Logger.Error().Msg("failed to create token")
+		utils.Logger.Error().Err(err).Msg("failed to create token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
 	}
 	c.JSON(http.StatusCreated, AuthResponse{
-		Email: "", // Empty string if first registering
-		Phone: user.Phone.String,
-		Token: token,
+		Email:        "", // Empty string if first registering
+		Phone:        user.Phone.String,
+		Token:        token,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -153,6 +183,18 @@ func (h *AuthHandler) LoginEmail(c *gin.Context) {
 		return
 	}
 
+	// Count the attempt up front and refuse while locked, before spending a password hash comparison
+	throttle := newLoginThrottle(c, "email:"+req.Email)
+	retryAfter, err := throttle.reserve(c, h.Queries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if retryAfter > 0 {
+		writeLoginLocked(c, retryAfter)
+		return
+	}
+
 	// Find user by email
 	user, err := h.Queries.GetUserByEmail(c, sql.NullString{String: req.Email, Valid: true})
 	if err != nil {
@@ -161,22 +203,14 @@ func (h *AuthHandler) LoginEmail(c *gin.Context) {
 	}
 
 	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if !checkPassword(c, h.Queries, user.ID, user.Password, req.Password) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
 		return
 	}
+	throttle.recordSuccess(c, h.Queries)
 
-	// Generate token
-	token, err := utils.GenerateJWTToken(uint(user.ID))
-	if err != nil {
-		utils.Logger.Error().Err(err)
-		utils.Logger.Error().Msg("failed to create token")
-	}
-	c.JSON(http.StatusOK, AuthResponse{
-		Email: user.Email.String,
-		Phone: utils.NullStringToString(user.Phone), // Could be empty if not linked
-		Token: token,
-	})
+	// Issue tokens, or a 2FA challenge when the user has TOTP enabled
+	finishLogin(c, h.Queries, user.ID, user.Email.String, utils.NullStringToString(user.Phone))
 }
 
 // Phone Login - POST /v1/login/phone
@@ -187,36 +221,77 @@ func (h *AuthHandler) LoginPhone(c *gin.Context) {
 		return
 	}
 
-	// Validate phone format
-	if !utils.ValidatePhone(req.Phone) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
-		return
+	// Phones are stored in E.164. Rows migration 000024 could not normalize, a format the
+	// parser rejects or a number another account already held in E.164, kept the form they
+	// were registered in and are matched exactly before the E.164 form.
+	raw := strings.TrimSpace(req.Phone)
+	phone, ok := utils.NormalizePhone(raw)
+	if !ok {
+		phone = raw
+	}
+	candidates := []string{phone}
+	if raw != phone {
+		candidates = []string{raw, phone}
 	}
 
-	// Find user by phone
-	user, err := h.Queries.GetUserByPhone(c, sql.NullString{String: req.Phone, Valid: true})
+	// Count the attempt up front and refuse while locked, before spending a password hash comparison
+	throttle := newLoginThrottle(c, "phone:"+phone)
+	retryAfter, err := throttle.reserve(c, h.Queries)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Phone not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if retryAfter > 0 {
+		writeLoginLocked(c, retryAfter)
 		return
 	}
 
-	// Compare password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Phone not found"})
+	for _, candidate := range candidates {
+		// Find user by phone
+		user, err := h.Queries.GetUserByPhone(c, sql.NullString{String: candidate, Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+
+		// Compare password
+		if !checkPassword(c, h.Queries, user.ID, user.Password, req.Password) {
+			continue
+		}
+		throttle.recordSuccess(c, h.Queries)
+
+		// Issue tokens, or a 2FA challenge when the user has TOTP enabled
+		finishLogin(c, h.Queries, user.ID, utils.NullStringToString(user.Email), user.Phone.String)
 		return
 	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Phone not found"})
+}
 
-	// Generate token
-	token, err := utils.GenerateJWTToken(uint(user.ID))
+// checkPassword verifies a login password. Hashes made with bcrypt or a lower argon2id
+// cost are replaced while the plaintext is at hand, a failed upgrade does not fail the login.
+func checkPassword(c *gin.Context, queries *repository.Queries, userID int32, hash, password string) bool {
+	ok, needsRehash, err := utils.Passwords.Verify(hash, password)
 	if err != nil {
-		utils.Logger.Error().Err(err)
-		utils.Logger.Error().Msg("failed to create token")
-	}
-	// Store token with user ID
-	utils.GlobalTokenStore.StoreToken(token, user.ID)
-	c.JSON(http.StatusOK, AuthResponse{
-		Email: utils.NullStringToString(user.Email), // Could be empty if not linked
-		Phone: user.Phone.String,
-		Token: token,
-	})
+		utils.Logger.Error().Err(err).Int32("user_id", userID).Msg("Failed to verify password hash")
+		return false
+	}
+	if !ok || !needsRehash {
+		return ok
+	}
+
+	newHash, err := utils.Passwords.Hash(password)
+	if err == nil {
+		err = queries.RehashUserPassword(c, repository.RehashUserPasswordParams{
+			NewPassword: newHash,
+			ID:          userID,
+			OldPassword: hash,
+		})
+	}
+	if err != nil {
+		utils.Logger.Error().Err(err).Int32("user_id", userID).Msg("Failed to upgrade password hash")
+	}
+	return true
 }
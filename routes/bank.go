@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BankHandler struct {
+	Queries *repository.Queries
+}
+
+func NewBankHandler(queries *repository.Queries) *BankHandler {
+	return &BankHandler{Queries: queries}
+}
+
+// Response struct
+type BankResponse struct {
+	BankCode               string `json:"bankCode"`
+	Name                   string `json:"name"`
+	Kind                   string `json:"kind"`
+	AccountNumberMinLength int32  `json:"accountNumberMinLength"`
+	AccountNumberMaxLength int32  `json:"accountNumberMaxLength"`
+	AccountNumberPattern   string `json:"accountNumberPattern"`
+}
+
+// resolveBankAccount looks up the bank and validates the account number against its
+// rules. It returns the normalized number, or writes the response and returns false.
+func resolveBankAccount(c *gin.Context, queries *repository.Queries, bankCode, accountNumber string) (repository.Bank, string, bool) {
+	bank, err := queries.GetBankByCode(c, strings.ToUpper(strings.TrimSpace(bankCode)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bankCode is not valid"})
+			return repository.Bank{}, "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return repository.Bank{}, "", false
+	}
+
+	number := utils.NormalizeBankAccountNumber(accountNumber)
+	if !utils.ValidateBankAccountNumber(number, bank.AccountNumberMinLength, bank.AccountNumberMaxLength, bank.AccountNumberPattern) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bankAccountNumber is not valid for " + bank.Name})
+		return repository.Bank{}, "", false
+	}
+	return bank, number, true
+}
+
+// GET /v1/banks
+func (h *BankHandler) ListBanks(c *gin.Context) {
+	banks, err := h.Queries.ListBanks(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]BankResponse, 0, len(banks))
+	for _, bank := range banks {
+		response = append(response, BankResponse{
+			BankCode:               bank.Code,
+			Name:                   bank.Name,
+			Kind:                   bank.Kind,
+			AccountNumberMinLength: bank.AccountNumberMinLength,
+			AccountNumberMaxLength: bank.AccountNumberMaxLength,
+			AccountNumberPattern:   bank.AccountNumberPattern,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,420 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BankAccountHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+}
+
+func NewBankAccountHandler(queries *repository.Queries, db *sql.DB) *BankAccountHandler {
+	return &BankAccountHandler{Queries: queries, DB: db}
+}
+
+// Request struct
+type BankAccountRequest struct {
+	// One of GET /v1/banks, the account number is validated against its rules
+	BankCode          string `json:"bankCode" binding:"required"`
+	BankAccountHolder string `json:"bankAccountHolder" binding:"required,min=4,max=32"`
+	BankAccountNumber string `json:"bankAccountNumber" binding:"required,max=32"`
+	// Makes this the default account, the previous default stays enabled
+	IsDefault bool `json:"isDefault"`
+	// Disabled accounts are kept but not shown to buyers, accounts are enabled when omitted
+	IsEnabled *bool `json:"isEnabled"`
+	// Required once two-factor authentication is enabled
+	TotpCode string `json:"totpCode"`
+}
+
+// DeleteBankAccountRequest is optional, only users with two-factor authentication send it
+type DeleteBankAccountRequest struct {
+	TotpCode string `json:"totpCode"`
+}
+
+// Response struct
+type BankAccountResponse struct {
+	BankAccountID     string    `json:"bankAccountId"`
+	BankCode          string    `json:"bankCode"`
+	BankAccountName   string    `json:"bankAccountName"`
+	BankAccountHolder string    `json:"bankAccountHolder"`
+	BankAccountNumber string    `json:"bankAccountNumber"`
+	IsDefault         bool      `json:"isDefault"`
+	IsEnabled         bool      `json:"isEnabled"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+func toBankAccountResponse(account repository.BankAccount) (BankAccountResponse, error) {
+	account, err := openBankAccount(account)
+	if err != nil {
+		return BankAccountResponse{}, err
+	}
+	return BankAccountResponse{
+		BankAccountID:     strconv.FormatInt(int64(account.ID), 10),
+		BankCode:          utils.NullStringToString(account.BankCode),
+		BankAccountName:   account.BankAccountName,
+		BankAccountHolder: account.BankAccountHolder,
+		BankAccountNumber: account.BankAccountNumber,
+		IsDefault:         account.IsDefault,
+		IsEnabled:         account.IsEnabled,
+		CreatedAt:         account.CreatedAt,
+		UpdatedAt:         account.UpdatedAt,
+	}, nil
+}
+
+// openBankAccount returns the account with its number decrypted
+func openBankAccount(account repository.BankAccount) (repository.BankAccount, error) {
+	number, err := utils.DataKeys.Decrypt(utils.FieldBankAccountNumber, account.BankAccountNumber)
+	if err != nil {
+		return repository.BankAccount{}, err
+	}
+	account.BankAccountNumber = number
+	return account, nil
+}
+
+// sealBankAccountNumber encrypts a normalized account number and returns it with its blind index
+func sealBankAccountNumber(number string) (string, sql.NullString, error) {
+	sealed, err := utils.DataKeys.Encrypt(utils.FieldBankAccountNumber, number)
+	if err != nil {
+		return "", sql.NullString{}, err
+	}
+	index := utils.DataKeys.BlindIndex(utils.FieldBankAccountNumber, number)
+	return sealed, sql.NullString{String: index, Valid: true}, nil
+}
+
+// checkBankAccountNumber seals the number and rejects it when another account of the
+// user already has it. It writes the response and returns false on failure.
+func checkBankAccountNumber(c *gin.Context, queries *repository.Queries, userID, accountID int32, bankCode, number string) (string, sql.NullString, bool) {
+	sealed, index, err := sealBankAccountNumber(number)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to encrypt bank account number")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return "", sql.NullString{}, false
+	}
+	exists, err := queries.BankAccountNumberExists(c, repository.BankAccountNumberExistsParams{
+		UserID:                userID,
+		ID:                    accountID,
+		BankCode:              sql.NullString{String: bankCode, Valid: true},
+		BankAccountNumberBidx: index,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return "", sql.NullString{}, false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "bankAccountNumber is already registered"})
+		return "", sql.NullString{}, false
+	}
+	return sealed, index, true
+}
+
+var errDefaultBankAccountDisabled = errors.New("the default account can not be disabled")
+
+// setDefaultBankAccount moves the default to accountID, the account must be enabled
+func setDefaultBankAccount(c *gin.Context, qtx *repository.Queries, userID, accountID int32) error {
+	if err := qtx.ClearDefaultBankAccount(c, userID); err != nil {
+		return err
+	}
+	updated, err := qtx.SetDefaultBankAccount(c, repository.SetDefaultBankAccountParams{
+		ID:     accountID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errDefaultBankAccountDisabled
+	}
+	return nil
+}
+
+// POST /v1/user/bank-accounts
+func (h *BankAccountHandler) CreateBankAccount(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req BankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	isEnabled := req.IsEnabled == nil || *req.IsEnabled
+	if req.IsDefault && !isEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default account can not be disabled"})
+		return
+	}
+
+	bank, accountNumber, ok := resolveBankAccount(c, h.Queries, req.BankCode, req.BankAccountNumber)
+	if !ok {
+		return
+	}
+	sealedNumber, numberIndex, ok := checkBankAccountNumber(c, h.Queries, userID, 0, bank.Code, accountNumber)
+	if !ok {
+		return
+	}
+
+	// Payments go to these accounts, adding one needs a fresh second factor
+	if !requireFreshTOTP(c, h.Queries, userID, req.TotpCode) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	// The accounts belong to the seller's store
+	store, err := qtx.GetOrCreateStore(c, userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to get store")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	account, err := qtx.CreateBankAccount(c, repository.CreateBankAccountParams{
+		UserID:                userID,
+		StoreID:               sql.NullInt32{Int32: store.ID, Valid: true},
+		BankCode:              sql.NullString{String: bank.Code, Valid: true},
+		BankAccountName:       bank.Name,
+		BankAccountHolder:     req.BankAccountHolder,
+		BankAccountNumber:     sealedNumber,
+		BankAccountNumberBidx: numberIndex,
+		IsEnabled:             isEnabled,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to create bank account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if req.IsDefault {
+		err = setDefaultBankAccount(c, qtx, userID, account.ID)
+	} else {
+		// The first enabled account becomes the default
+		err = qtx.EnsureDefaultBankAccount(c, userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	account, err = qtx.GetBankAccount(c, repository.GetBankAccountParams{ID: account.ID, UserID: userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response, err := toBankAccountResponse(account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GET /v1/user/bank-accounts
+func (h *BankAccountHandler) ListBankAccounts(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	accounts, err := h.Queries.ListBankAccountsByUserID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]BankAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		item, err := toBankAccountResponse(account)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to decrypt bank account")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		response = append(response, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /v1/user/bank-accounts/:id
+func (h *BankAccountHandler) UpdateBankAccount(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bankAccountId is not found"})
+		return
+	}
+
+	var req BankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	current, err := h.Queries.GetBankAccount(c, repository.GetBankAccountParams{ID: int32(accountID), UserID: userID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "bankAccountId is not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	isEnabled := current.IsEnabled
+	if req.IsEnabled != nil {
+		isEnabled = *req.IsEnabled
+	}
+	// Another account has to become the default before this one can be disabled
+	if (current.IsDefault || req.IsDefault) && !isEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default account can not be disabled"})
+		return
+	}
+	bank, accountNumber, ok := resolveBankAccount(c, h.Queries, req.BankCode, req.BankAccountNumber)
+	if !ok {
+		return
+	}
+	sealedNumber, numberIndex, ok := checkBankAccountNumber(c, h.Queries, userID, current.ID, bank.Code, accountNumber)
+	if !ok {
+		return
+	}
+
+	if !requireFreshTOTP(c, h.Queries, userID, req.TotpCode) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	_, err = qtx.UpdateBankAccount(c, repository.UpdateBankAccountParams{
+		ID:                    current.ID,
+		UserID:                userID,
+		BankCode:              sql.NullString{String: bank.Code, Valid: true},
+		BankAccountName:       bank.Name,
+		BankAccountHolder:     req.BankAccountHolder,
+		BankAccountNumber:     sealedNumber,
+		BankAccountNumberBidx: numberIndex,
+		IsEnabled:             isEnabled,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to update bank account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if req.IsDefault && !current.IsDefault {
+		err = setDefaultBankAccount(c, qtx, userID, current.ID)
+	} else {
+		err = qtx.EnsureDefaultBankAccount(c, userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	account, err := qtx.GetBankAccount(c, repository.GetBankAccountParams{ID: current.ID, UserID: userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response, err := toBankAccountResponse(account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /v1/user/bank-accounts/:id
+func (h *BankAccountHandler) DeleteBankAccount(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bankAccountId is not found"})
+		return
+	}
+
+	var req DeleteBankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	// Removing the default moves payouts to another account, like adding one it needs a fresh second factor
+	if !requireFreshTOTP(c, h.Queries, userID, req.TotpCode) {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	deleted, err := qtx.DeleteBankAccount(c, repository.DeleteBankAccountParams{
+		ID:     int32(accountID),
+		UserID: userID,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to delete bank account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "bankAccountId is not found"})
+		return
+	}
+	// When the default was deleted the oldest remaining account takes over
+	if err := qtx.EnsureDefaultBankAccount(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,289 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	Queries *repository.Queries
+}
+
+func NewCategoryHandler(queries *repository.Queries) *CategoryHandler {
+	return &CategoryHandler{Queries: queries}
+}
+
+// Request struct
+type CategoryRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=64"`
+	// Derived from the name when omitted
+	Slug string `json:"slug" binding:"omitempty,max=64"`
+	// Omitted for a top level category
+	ParentID string `json:"parentId"`
+	// Inactive categories are hidden from the tree and can not be picked for products,
+	// categories are active when omitted
+	IsActive *bool `json:"isActive"`
+}
+
+// Response structs
+type CategoryResponse struct {
+	CategoryID string    `json:"categoryId"`
+	Name       string    `json:"name"`
+	Slug       string    `json:"slug"`
+	ParentID   string    `json:"parentId"`
+	IsActive   bool      `json:"isActive"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CategoryTreeResponse struct {
+	CategoryID string                 `json:"categoryId"`
+	Name       string                 `json:"name"`
+	Slug       string                 `json:"slug"`
+	Children   []CategoryTreeResponse `json:"children"`
+}
+
+func toCategoryResponse(category repository.ProductCategory) CategoryResponse {
+	return CategoryResponse{
+		CategoryID: strconv.FormatInt(int64(category.ProductCategoryID), 10),
+		Name:       category.Name,
+		Slug:       category.Slug,
+		ParentID:   utils.NullInt32ToString(category.ParentID),
+		IsActive:   category.IsActive,
+		CreatedAt:  category.CreatedAt,
+		UpdatedAt:  category.UpdatedAt,
+	}
+}
+
+// resolveProductCategory finds the active category a product is filed under.
+// It writes the response and returns false on failure.
+func resolveProductCategory(c *gin.Context, queries *repository.Queries, name string) (repository.ProductCategory, bool) {
+	category, err := queries.GetProductCategoryByName(c, name)
+	if err == nil && !category.IsActive {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+			return repository.ProductCategory{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return repository.ProductCategory{}, false
+	}
+	return category, true
+}
+
+// categoryTree nests the active categories under parentID, an inactive category hides its subtree
+func categoryTree(children map[int32][]repository.ProductCategory, parentID int32) []CategoryTreeResponse {
+	tree := make([]CategoryTreeResponse, 0, len(children[parentID]))
+	for _, category := range children[parentID] {
+		if !category.IsActive {
+			continue
+		}
+		tree = append(tree, CategoryTreeResponse{
+			CategoryID: strconv.FormatInt(int64(category.ProductCategoryID), 10),
+			Name:       category.Name,
+			Slug:       category.Slug,
+			Children:   categoryTree(children, category.ProductCategoryID),
+		})
+	}
+	return tree
+}
+
+// GET /v1/category
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	categories, err := h.Queries.ListCategories(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Top level categories are grouped under 0, serial ids start at 1
+	children := make(map[int32][]repository.ProductCategory)
+	for _, category := range categories {
+		children[category.ParentID.Int32] = append(children[category.ParentID.Int32], category)
+	}
+
+	c.JSON(http.StatusOK, categoryTree(children, 0))
+}
+
+// GET /v1/admin/categories
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	categories, err := h.Queries.ListCategories(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]CategoryResponse, 0, len(categories))
+	for _, category := range categories {
+		response = append(response, toCategoryResponse(category))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// bindCategory validates the request of a create or update. categoryID is 0 for a new
+// category. It writes the response and returns false on failure.
+func (h *CategoryHandler) bindCategory(c *gin.Context, categoryID int32) (repository.CreateCategoryParams, bool) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return repository.CreateCategoryParams{}, false
+	}
+
+	params := repository.CreateCategoryParams{
+		Name:     strings.TrimSpace(req.Name),
+		Slug:     req.Slug,
+		IsActive: req.IsActive == nil || *req.IsActive,
+	}
+	if params.Slug == "" {
+		params.Slug = utils.Slugify(params.Name)
+	}
+	if params.Name == "" || !utils.ValidSlug(params.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return repository.CreateCategoryParams{}, false
+	}
+
+	if req.ParentID != "" {
+		parentID, err := strconv.Atoi(req.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parentId is not valid"})
+			return repository.CreateCategoryParams{}, false
+		}
+		if _, err := h.Queries.GetCategoryByID(c, int32(parentID)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parentId is not valid"})
+				return repository.CreateCategoryParams{}, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return repository.CreateCategoryParams{}, false
+		}
+		// A category can not move under itself or one of its subcategories
+		if categoryID != 0 {
+			cycle, err := h.Queries.IsCategoryInSubtree(c, repository.IsCategoryInSubtreeParams{
+				RootID:     categoryID,
+				CategoryID: int32(parentID),
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+				return repository.CreateCategoryParams{}, false
+			}
+			if cycle {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "parentId is not valid"})
+				return repository.CreateCategoryParams{}, false
+			}
+		}
+		params.ParentID = sql.NullInt32{Int32: int32(parentID), Valid: true}
+	}
+
+	return params, true
+}
+
+// writeCategoryError answers a failed insert or update of a category
+func writeCategoryError(c *gin.Context, err error) {
+	if strings.Contains(err.Error(), "duplicate key") {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category name or slug already exists"})
+		return
+	}
+	utils.Logger.Error().Err(err).Msg("Failed to save category")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+}
+
+// POST /v1/admin/categories
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	params, ok := h.bindCategory(c, 0)
+	if !ok {
+		return
+	}
+
+	category, err := h.Queries.CreateCategory(c, params)
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toCategoryResponse(category))
+}
+
+// PUT /v1/admin/categories/:categoryId
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("categoryId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "categoryId is not found"})
+		return
+	}
+	if _, err := h.Queries.GetCategoryByID(c, int32(categoryID)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "categoryId is not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	params, ok := h.bindCategory(c, int32(categoryID))
+	if !ok {
+		return
+	}
+
+	// A rename also refreshes the search documents of its products, see trg_product_category_search
+	category, err := h.Queries.UpdateCategory(c, repository.UpdateCategoryParams{
+		ProductCategoryID: int32(categoryID),
+		Name:              params.Name,
+		Slug:              params.Slug,
+		ParentID:          params.ParentID,
+		IsActive:          params.IsActive,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "categoryId is not found"})
+			return
+		}
+		writeCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toCategoryResponse(category))
+}
+
+// DELETE /v1/admin/categories/:categoryId
+// Categories still in use can only be deactivated.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("categoryId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "categoryId is not found"})
+		return
+	}
+
+	usage, err := h.Queries.CountCategoryUsage(c, sql.NullInt32{Int32: int32(categoryID), Valid: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if usage.Children > 0 || usage.Products > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories or products"})
+		return
+	}
+
+	deleted, err := h.Queries.DeleteCategory(c, int32(categoryID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "categoryId is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
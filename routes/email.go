@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const emailLinkTTL = 24 * time.Hour
+
+type EmailHandler struct {
+	Queries *repository.Queries
+	Mailer  provider.Mailer
+}
+
+func NewEmailHandler(queries *repository.Queries, mailer provider.Mailer) *EmailHandler {
+	return &EmailHandler{Queries: queries, Mailer: mailer}
+}
+
+// sendEmailLink mails a signed link that proves the recipient owns the address
+func sendEmailLink(c *gin.Context, mailer provider.Mailer, userID int32, email string, purpose string) error {
+	token, err := utils.SignLinkToken(utils.LinkClaims{
+		UserID:  userID,
+		Email:   email,
+		Purpose: purpose,
+	}, emailLinkTTL)
+	if err != nil {
+		return err
+	}
+	link := utils.LinkURL("/v1/email/verify", token)
+
+	subject := "Verify your email address"
+	body := fmt.Sprintf("Please confirm that this is your email address by opening the link below.\n\n%s\n\nThe link expires in 24 hours.", link)
+	if purpose == utils.LinkPurposeChangeEmail {
+		subject = "Confirm your new email address"
+		body = fmt.Sprintf("Someone asked to use this address for a TutupLapak account. Open the link below to confirm the change.\n\n%s\n\nThe link expires in 24 hours. If you did not ask for this, ignore this email.", link)
+	}
+	return mailer.SendMail(c, email, subject, body)
+}
+
+// GET /v1/email/verify
+func (h *EmailHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+
+	purpose := utils.LinkPurposeVerifyEmail
+	claims, err := utils.VerifyLinkToken(token, purpose)
+	if err != nil {
+		purpose = utils.LinkPurposeChangeEmail
+		claims, err = utils.VerifyLinkToken(token, purpose)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, claims.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if purpose == utils.LinkPurposeVerifyEmail {
+		// The link is only valid for the address it was sent to
+		if user.Email.String != claims.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		if user.EmailVerifiedAt.Valid {
+			c.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+			return
+		}
+	} else {
+		if user.PendingEmail.String != claims.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired link"})
+			return
+		}
+		// Check if email was taken while the change was pending
+		existing, err := h.Queries.GetUserByEmail(c, sql.NullString{String: claims.Email, Valid: true})
+		if err == nil && existing.ID != user.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is taken"})
+			return
+		}
+	}
+
+	err = h.Queries.MarkEmailVerified(c, repository.MarkEmailVerifiedParams{
+		ID:    user.ID,
+		Email: sql.NullString{String: claims.Email, Valid: true},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is taken"})
+			return
+		}
+		utils.Logger.Error().Err(err).Msg("Failed to verify email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if purpose == utils.LinkPurposeChangeEmail && user.Email.String != "" {
+		// Tell the old address so a hijacked account does not go unnoticed
+		body := fmt.Sprintf("The email address of your TutupLapak account was changed to %s.\n\nIf you did not make this change, reset your password and contact support immediately.", claims.Email)
+		if err := h.Mailer.SendMail(c, user.Email.String, "Your email address was changed", body); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to notify old email address")
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// POST /v1/email/verify/resend
+func (h *EmailHandler) ResendVerification(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	email := utils.NullStringToString(user.Email)
+	if email == "" {
+		// A first email waits as the pending one until its link is opened
+		pending := utils.NullStringToString(user.PendingEmail)
+		if pending == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No email linked"})
+			return
+		}
+		if err := sendEmailLink(c, h.Mailer, user.ID, pending, utils.LinkPurposeChangeEmail); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to send email confirmation")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	if err := sendEmailLink(c, h.Mailer, user.ID, email, utils.LinkPurposeVerifyEmail); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to send verification email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
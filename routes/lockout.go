@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutHandler struct {
+	Queries *repository.Queries
+}
+
+func NewLockoutHandler(queries *repository.Queries) *LockoutHandler {
+	return &LockoutHandler{Queries: queries}
+}
+
+// Response struct
+type LockoutResponse struct {
+	Key           string     `json:"key"`
+	Failures      int32      `json:"failures"`
+	LockedUntil   *time.Time `json:"lockedUntil"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+}
+
+// loginThrottle identifies the counters a login attempt is checked against
+type loginThrottle struct {
+	AccountKey string
+	IPKey      string
+}
+
+// newLoginThrottle keys the account counter by account, e.g. "email:user@example.com"
+func newLoginThrottle(c *gin.Context, account string) loginThrottle {
+	return loginThrottle{
+		AccountKey: "account:" + strings.ToLower(account),
+		IPKey:      "ip:" + c.ClientIP(),
+	}
+}
+
+// limits maps each counter to the attempts it allows before locking
+func (t loginThrottle) limits() map[string]int32 {
+	return map[string]int32{
+		t.AccountKey: utils.LoginAccountFreeAttempts,
+		t.IPKey:      utils.LoginIPFreeAttempts,
+	}
+}
+
+// reserve counts an attempt against both the account and the client IP before the secret
+// is compared, so concurrent guesses can not all get through before a failure is recorded.
+// It returns how long the caller has to wait when either is locked, the attempt is then not
+// counted. A failed attempt needs nothing more, a successful one calls recordSuccess.
+func (t loginThrottle) reserve(c *gin.Context, queries *repository.Queries) (time.Duration, error) {
+	now := time.Now()
+	var reserved []string
+	var locked bool
+	var retryAfter time.Duration
+	for key, freeAttempts := range t.limits() {
+		_, err := queries.ReserveLoginAttempt(c, repository.ReserveLoginAttemptParams{
+			Key:             key,
+			LastFailureAt:   now.Add(-utils.LoginFailureWindow),
+			FreeAttempts:    freeAttempts,
+			LockBaseSeconds: utils.LoginLockBase.Seconds(),
+			LockMaxSeconds:  utils.LoginLockMax.Seconds(),
+		})
+		if err == nil {
+			reserved = append(reserved, key)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+
+		// Locked keys are left untouched
+		locked = true
+		throttle, err := queries.GetLoginThrottle(c, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := time.Until(throttle.LockedUntil.Time); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if locked {
+		// A refused attempt does not count against the other key
+		for _, key := range reserved {
+			t.release(c, queries, key)
+		}
+		// The lock may run out in between, the caller still waits a moment
+		return max(retryAfter, time.Second), nil
+	}
+	return 0, nil
+}
+
+// release takes back an attempt reserved for key
+func (t loginThrottle) release(c *gin.Context, queries *repository.Queries, key string) {
+	err := queries.ReleaseLoginAttempt(c, repository.ReleaseLoginAttemptParams{
+		Key:          key,
+		FreeAttempts: t.limits()[key],
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to release login attempt")
+	}
+}
+
+// recordSuccess resets the account counter and takes back the IP's attempt. The rest of the IP
+// counter is kept, otherwise signing in to an own account would let an attacker keep guessing
+// other accounts from the same IP.
+func (t loginThrottle) recordSuccess(c *gin.Context, queries *repository.Queries) {
+	if _, err := queries.ClearLoginThrottle(c, t.AccountKey); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to clear login failures")
+	}
+	t.release(c, queries, t.IPKey)
+}
+
+func writeLoginLocked(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts"})
+}
+
+// GET /v1/admin/lockouts
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+			return
+		}
+		limit = parsed
+	}
+
+	throttles, err := h.Queries.ListLoginThrottles(c, repository.ListLoginThrottlesParams{
+		LastFailureAt: time.Now().Add(-utils.LoginFailureWindow),
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]LockoutResponse, 0, len(throttles))
+	for _, throttle := range throttles {
+		item := LockoutResponse{
+			Key:           throttle.Key,
+			Failures:      throttle.Failures,
+			LastFailureAt: throttle.LastFailureAt,
+		}
+		if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(time.Now()) {
+			lockedUntil := throttle.LockedUntil.Time
+			item.LockedUntil = &lockedUntil
+		}
+		response = append(response, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /v1/admin/lockouts?key=account:email:user@example.com
+// Keys are as listed by GET /v1/admin/lockouts: account:email:<email>, account:phone:<E.164 phone>,
+// account:2fa:<user id> or ip:<client ip>.
+func (h *LockoutHandler) ClearLockout(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	cleared, err := h.Queries.ClearLoginThrottle(c, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if cleared == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "lockout is not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
+
+// Cleanup old counters periodically
+func (h *LockoutHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			cutoff := time.Now().Add(-utils.LoginFailureWindow)
+			if err := h.Queries.DeleteStaleLoginThrottles(context.Background(), cutoff); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete stale login throttles")
+			}
+		}
+	}()
+}
@@ -0,0 +1,224 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a user has to finish the login at the provider
+const oidcStateTTL = 10 * time.Minute
+
+type OIDCHandler struct {
+	Queries   *repository.Queries
+	DB        *sql.DB
+	Providers map[string]*provider.OIDCProvider
+}
+
+func NewOIDCHandler(queries *repository.Queries, db *sql.DB, providers map[string]*provider.OIDCProvider) *OIDCHandler {
+	return &OIDCHandler{Queries: queries, DB: db, Providers: providers}
+}
+
+// GET /v1/login/oidc/:provider
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	oidcProvider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "provider is not found"})
+		return
+	}
+
+	state := utils.GenerateToken()
+	nonce := utils.GenerateToken()
+	verifier, challenge := provider.NewPKCEVerifier()
+
+	err := h.Queries.CreateOIDCState(c, repository.CreateOIDCStateParams{
+		StateHash:    utils.HashToken(state),
+		Provider:     oidcProvider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to store OIDC state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	authURL, err := oidcProvider.AuthCodeURL(c, state, nonce, challenge)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("provider", oidcProvider.Name).Msg("Failed to build OIDC login URL")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GET /v1/login/oidc/:provider/callback
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	oidcProvider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "provider is not found"})
+		return
+	}
+
+	if c.Query("error") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login was cancelled at the provider"})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	pending, err := h.Queries.ConsumeOIDCState(c, repository.ConsumeOIDCStateParams{
+		StateHash: utils.HashToken(state),
+		Provider:  oidcProvider.Name,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	idToken, err := oidcProvider.Exchange(c, code, pending.CodeVerifier)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("provider", oidcProvider.Name).Msg("Failed to exchange OIDC code")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+	identity, err := oidcProvider.VerifyIDToken(c, idToken, pending.Nonce)
+	if err != nil {
+		utils.Logger.Error().Err(err).Str("provider", oidcProvider.Name).Msg("Invalid OIDC ID token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Accounts are matched by email, which is only safe when the provider vouches for it
+	if identity.Email == "" || !identity.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A verified email is required"})
+		return
+	}
+
+	user, err := h.findOrCreateUser(c, oidcProvider.Name, identity)
+	if err != nil {
+		if errors.Is(err, errOIDCEmailUnverified) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email exists, sign in with its password and verify the email first"})
+			return
+		}
+		utils.Logger.Error().Err(err).Msg("Failed to sign in with OIDC")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Issue tokens, or a 2FA challenge when the user has TOTP enabled
+	finishLogin(c, h.Queries, user.ID, utils.NullStringToString(user.Email), utils.NullStringToString(user.Phone))
+}
+
+var errOIDCEmailUnverified = errors.New("account email is not verified")
+
+// findOrCreateUser resolves the user for a provider identity: a linked identity first,
+// then an account with the same email, otherwise a new account. An account whose email
+// was never verified is not linked, whoever registered it may not own the address and
+// would keep their phone, second factor, API keys and payout accounts on it.
+func (h *OIDCHandler) findOrCreateUser(c *gin.Context, providerName string, identity *provider.OIDCIdentity) (repository.User, error) {
+	linked, err := h.Queries.GetUserIdentity(c, repository.GetUserIdentityParams{
+		Provider: providerName,
+		Subject:  identity.Subject,
+	})
+	if err == nil {
+		user, err := h.Queries.GetUserByID(c, linked.UserID)
+		return user, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return repository.User{}, err
+	}
+
+	// Accounts created here get a random password, the owner can set one by resetting it
+	unusablePassword, err := utils.Passwords.Hash(utils.GenerateToken())
+	if err != nil {
+		return repository.User{}, err
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		return repository.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	email := sql.NullString{String: identity.Email, Valid: true}
+	var userID int32
+	existing, err := qtx.GetUserByEmail(c, email)
+	switch {
+	case err == nil:
+		userID = existing.ID
+		user, err := qtx.GetUserByID(c, userID)
+		if err != nil {
+			return repository.User{}, err
+		}
+		if !user.EmailVerifiedAt.Valid {
+			return repository.User{}, errOIDCEmailUnverified
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		created, err := qtx.CreateUserWithEmail(c, repository.CreateUserWithEmailParams{
+			Email:    email,
+			Password: unusablePassword,
+			Phone:    sql.NullString{String: "", Valid: false},
+		})
+		if err != nil {
+			return repository.User{}, err
+		}
+		userID = created.ID
+	default:
+		return repository.User{}, err
+	}
+
+	// The provider verified the address, so the account's email counts as verified too
+	err = qtx.MarkEmailVerified(c, repository.MarkEmailVerifiedParams{ID: userID, Email: email})
+	if err != nil {
+		return repository.User{}, err
+	}
+	err = qtx.CreateUserIdentity(c, repository.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		return repository.User{}, err
+	}
+
+	user, err := qtx.GetUserByID(c, userID)
+	if err != nil {
+		return repository.User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return repository.User{}, err
+	}
+	return user, nil
+}
+
+// Cleanup expired login states periodically
+func (h *OIDCHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			if err := h.Queries.DeleteExpiredOIDCStates(context.Background()); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired OIDC states")
+			}
+		}
+	}()
+}
@@ -0,0 +1,314 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type OTPHandler struct {
+	Queries *repository.Queries
+	SMS     provider.SMSSender
+}
+
+func NewOTPHandler(queries *repository.Queries, sms provider.SMSSender) *OTPHandler {
+	return &OTPHandler{Queries: queries, SMS: sms}
+}
+
+// Request structs
+type SendPhoneOTPRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+type VerifyPhoneOTPRequest struct {
+	Phone string `json:"phone" binding:"required"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// Text of the code sent to verify a phone, %s is the code
+const verifyPhoneMessage = "Your TutupLapak verification code is %s. Do not share this code with anyone."
+
+var (
+	errOTPInvalid         = errors.New("invalid or expired code")
+	errOTPTooManyAttempts = errors.New("too many attempts")
+)
+
+// otpRateLimitError is returned when a phone asks for codes too often
+type otpRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *otpRateLimitError) Error() string {
+	return fmt.Sprintf("too many codes requested, retry after %s", e.RetryAfter)
+}
+
+// sendPhoneOTP issues a new code for the phone and purpose and sends it by SMS.
+// messageFormat must contain a single %s which is replaced by the code.
+func sendPhoneOTP(c context.Context, queries *repository.Queries, sms provider.SMSSender, userID sql.NullInt32, phone, purpose, messageFormat string) error {
+	now := time.Now()
+	recent, err := queries.CountPhoneOTPsSince(c, repository.CountPhoneOTPsSinceParams{
+		Phone:     phone,
+		Purpose:   purpose,
+		CreatedAt: sql.NullTime{Time: now.Add(-utils.OTPResendInterval), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return &otpRateLimitError{RetryAfter: utils.OTPResendInterval}
+	}
+
+	hourly, err := queries.CountPhoneOTPsSince(c, repository.CountPhoneOTPsSinceParams{
+		Phone:     phone,
+		Purpose:   purpose,
+		CreatedAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if hourly >= utils.OTPMaxPerHour {
+		return &otpRateLimitError{RetryAfter: time.Hour}
+	}
+
+	code := utils.GenerateOTPCode()
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := queries.InvalidatePhoneOTPs(c, repository.InvalidatePhoneOTPsParams{Phone: phone, Purpose: purpose}); err != nil {
+		return err
+	}
+	_, err = queries.CreatePhoneOTP(c, repository.CreatePhoneOTPParams{
+		UserID:    userID,
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  string(codeHash),
+		ExpiresAt: now.Add(utils.OTPTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return sms.SendSMS(c, phone, fmt.Sprintf(messageFormat, code))
+}
+
+// verifyPhoneOTP checks a code against the latest one issued for the phone and purpose
+// and consumes it on success. Every guess counts towards the attempt limit, it is taken
+// before the code is compared.
+func verifyPhoneOTP(c *gin.Context, queries *repository.Queries, phone, purpose, code string) (repository.PhoneOtp, error) {
+	otp, err := queries.GetLatestPhoneOTP(c, repository.GetLatestPhoneOTPParams{Phone: phone, Purpose: purpose})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return otp, errOTPInvalid
+		}
+		return otp, err
+	}
+
+	if time.Now().After(otp.ExpiresAt) {
+		return otp, errOTPInvalid
+	}
+	attempts, err := queries.ReservePhoneOTPAttempt(c, repository.ReservePhoneOTPAttemptParams{
+		ID:          otp.ID,
+		MaxAttempts: utils.OTPMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Used up, or consumed by a concurrent request
+			return otp, errOTPTooManyAttempts
+		}
+		return otp, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(otp.CodeHash), []byte(code)); err != nil {
+		if attempts >= utils.OTPMaxAttempts {
+			// Burn the code so it can not be guessed any further
+			if _, err := queries.ConsumePhoneOTP(c, otp.ID); err != nil {
+				return otp, err
+			}
+			return otp, errOTPTooManyAttempts
+		}
+		return otp, errOTPInvalid
+	}
+
+	consumed, err := queries.ConsumePhoneOTP(c, otp.ID)
+	if err != nil {
+		return otp, err
+	}
+	if consumed == 0 {
+		return otp, errOTPInvalid
+	}
+	return otp, nil
+}
+
+// writeOTPError maps OTP errors to responses
+func writeOTPError(c *gin.Context, err error) {
+	var rateLimitErr *otpRateLimitError
+	switch {
+	case errors.As(err, &rateLimitErr):
+		c.Header("Retry-After", strconv.Itoa(int(rateLimitErr.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+	case errors.Is(err, errOTPTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, request a new code"})
+	case errors.Is(err, errOTPInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+	default:
+		utils.Logger.Error().Err(err).Msg("OTP error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+	}
+}
+
+// phoneVerifiedByOther reports whether another account has verified the number,
+// only then is the number taken
+func phoneVerifiedByOther(c *gin.Context, queries *repository.Queries, phone string, userID int32) (bool, error) {
+	holder, err := queries.GetPhoneHolder(c, sql.NullString{String: phone, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return holder.ID != userID && holder.PhoneVerifiedAt.Valid, nil
+}
+
+// POST /v1/otp/phone
+func (h *OTPHandler) SendPhoneOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req SendPhoneOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	// Validate phone format, phones are stored and looked up in E.164
+	phone, ok := utils.NormalizePhone(req.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	req.Phone = phone
+
+	// Check if phone is already taken by someone else, unverified claims can be taken over
+	taken, err := phoneVerifiedByOther(c, h.Queries, req.Phone, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Phone is taken"})
+		return
+	}
+
+	err = sendPhoneOTP(c, h.Queries, h.SMS, sql.NullInt32{Int32: userID, Valid: true}, req.Phone,
+		utils.OTPPurposeVerifyPhone, verifyPhoneMessage)
+	if err != nil {
+		writeOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Verification code sent",
+		"expiresIn": int(utils.OTPTTL.Seconds()),
+	})
+}
+
+// POST /v1/otp/phone/verify
+func (h *OTPHandler) VerifyPhoneOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req VerifyPhoneOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	phone, ok := utils.NormalizePhone(req.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	req.Phone = phone
+
+	otp, err := verifyPhoneOTP(c, h.Queries, req.Phone, utils.OTPPurposeVerifyPhone, req.Code)
+	if err == nil && (!otp.UserID.Valid || otp.UserID.Int32 != userID) {
+		err = errOTPInvalid
+	}
+	if err != nil {
+		writeOTPError(c, err)
+		return
+	}
+
+	// Check again, the phone may have been verified by someone else while the code was in flight
+	taken, err := phoneVerifiedByOther(c, h.Queries, req.Phone, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Phone is taken"})
+		return
+	}
+
+	// The code proves the number is the user's, other accounts' unverified claims on it are dropped
+	err = h.Queries.ReleaseUnverifiedPhone(c, repository.ReleaseUnverifiedPhoneParams{
+		Phone: sql.NullString{String: req.Phone, Valid: true},
+		ID:    userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Link the verified phone to user
+	updatedUser, err := h.Queries.MarkPhoneVerified(c, repository.MarkPhoneVerifiedParams{
+		ID:    userID,
+		Phone: sql.NullString{String: req.Phone, Valid: true},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Phone is taken"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response, err := profileResponse(c, h.Queries, userID, updatedUser.Email, updatedUser.Phone, updatedUser.StoreName, updatedUser.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Cleanup expired codes periodically
+func (h *OTPHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			if err := h.Queries.DeleteExpiredPhoneOTPs(context.Background()); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired phone codes")
+			}
+		}
+	}()
+}
@@ -0,0 +1,384 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	// Minimum time between two reset emails for the same account
+	passwordResetResendInterval = time.Minute
+	// Reset messages are delivered after the response, within this time
+	passwordResetDeliveryTimeout = time.Minute
+)
+
+type PasswordHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+	Mailer  provider.Mailer
+	SMS     provider.SMSSender
+}
+
+func NewPasswordHandler(queries *repository.Queries, db *sql.DB, mailer provider.Mailer, sms provider.SMSSender) *PasswordHandler {
+	return &PasswordHandler{Queries: queries, DB: db, Mailer: mailer, SMS: sms}
+}
+
+// Request structs
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+	Phone string `json:"phone"`
+}
+
+// ResetPasswordRequest takes either the token from the emailed link or the phone and the code sent to it
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Phone    string `json:"phone"`
+	Code     string `json:"code" binding:"omitempty,len=6,numeric"`
+	Password string `json:"password" binding:"required,min=8,max=32"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"currentPassword" binding:"required"`
+	NewPassword         string `json:"newPassword" binding:"required,min=8,max=32"`
+	SignOutOtherDevices bool   `json:"signOutOtherDevices"`
+}
+
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// The same answer is given whether or not the account exists
+const forgotPasswordMessage = "If the account exists, reset instructions have been sent"
+
+// POST /v1/password/forgot
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	if (req.Email == "") == (req.Phone == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	if req.Phone != "" {
+		// Validate phone format, phones are stored and looked up in E.164
+		phone, ok := utils.NormalizePhone(req.Phone)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+			return
+		}
+		req.Phone = phone
+	}
+
+	// Looking up the account and delivering run after the response, its timing must
+	// not tell whether the account exists
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetDeliveryTimeout)
+		defer cancel()
+		if req.Email != "" {
+			h.sendResetEmail(ctx, req.Email)
+		} else {
+			h.sendResetCode(ctx, req.Phone)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": forgotPasswordMessage})
+}
+
+// sendResetEmail mails a reset link if an account uses the email. Failures are only
+// logged, the caller must not be able to tell whether anything was sent.
+func (h *PasswordHandler) sendResetEmail(c context.Context, email string) {
+	user, err := h.Queries.GetUserByEmail(c, sql.NullString{String: email, Valid: true})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logger.Error().Err(err).Msg("Failed to get user for password reset")
+		}
+		return
+	}
+
+	recent, err := h.Queries.CountPasswordResetTokensSince(c, repository.CountPasswordResetTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: sql.NullTime{Time: time.Now().Add(-passwordResetResendInterval), Valid: true},
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to count password reset tokens")
+		return
+	}
+	if recent > 0 {
+		return
+	}
+
+	if err := h.Queries.InvalidateUserPasswordResetTokens(c, user.ID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to invalidate password reset tokens")
+		return
+	}
+	token := utils.GenerateToken()
+	err = h.Queries.CreatePasswordResetToken(c, repository.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to create password reset token")
+		return
+	}
+
+	link := utils.LinkURL("/reset-password", token)
+	body := fmt.Sprintf("We received a request to reset the password of your TutupLapak account. Open the link below to choose a new password.\n\n%s\n\nThe link expires in 30 minutes and can only be used once. If you did not ask for this, ignore this email.", link)
+	if err := h.Mailer.SendMail(c, email, "Reset your password", body); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to send password reset email")
+	}
+}
+
+// sendResetCode texts a reset code if an account uses the phone. Rate limits are
+// applied silently for the same reason as in sendResetEmail.
+func (h *PasswordHandler) sendResetCode(c context.Context, phone string) {
+	user, err := h.Queries.GetUserByPhone(c, sql.NullString{String: phone, Valid: true})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logger.Error().Err(err).Msg("Failed to get user for password reset")
+		}
+		return
+	}
+
+	err = sendPhoneOTP(c, h.Queries, h.SMS, sql.NullInt32{Int32: user.ID, Valid: true}, phone,
+		utils.OTPPurposeResetPassword, "Your TutupLapak password reset code is %s. Do not share this code with anyone.")
+	var rateLimitErr *otpRateLimitError
+	if err != nil && !errors.As(err, &rateLimitErr) {
+		utils.Logger.Error().Err(err).Msg("Failed to send password reset code")
+	}
+}
+
+// POST /v1/password/reset
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	byToken := req.Token != ""
+	byCode := req.Phone != "" && req.Code != ""
+	if byToken == byCode {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	if utils.IsCommonPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too common"})
+		return
+	}
+
+	// The password is only hashed once the token or code is known to be valid,
+	// junk requests must not cost argon2 work
+	var userID int32
+	var err error
+	if byToken {
+		userID, err = h.resetWithToken(c, req.Token, req.Password)
+		if err != nil {
+			if errors.Is(err, errResetTokenInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			utils.Logger.Error().Err(err).Msg("Failed to reset password")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+	} else {
+		// Codes were sent to the E.164 form of the number
+		if phone, ok := utils.NormalizePhone(req.Phone); ok {
+			req.Phone = phone
+		}
+		otp, err := verifyPhoneOTP(c, h.Queries, req.Phone, utils.OTPPurposeResetPassword, req.Code)
+		if err == nil && !otp.UserID.Valid {
+			err = errOTPInvalid
+		}
+		if err != nil {
+			writeOTPError(c, err)
+			return
+		}
+		// The phone may have moved to another account since the code was sent
+		user, err := h.Queries.GetUserByPhone(c, sql.NullString{String: req.Phone, Valid: true})
+		if err != nil || user.ID != otp.UserID.Int32 {
+			writeOTPError(c, errOTPInvalid)
+			return
+		}
+		userID = user.ID
+
+		hashedPassword, err := utils.Passwords.Hash(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		err = h.Queries.UpdateUserPassword(c, repository.UpdateUserPasswordParams{
+			ID:       userID,
+			Password: hashedPassword,
+		})
+		if err == nil {
+			err = h.Queries.InvalidateUserPasswordResetTokens(c, userID)
+		}
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to reset password")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+	}
+
+	// Whoever knew the old password must not stay signed in
+	if err := revokeAllUserTokens(c, h.Queries, userID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to revoke sessions after password reset")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// resetWithToken consumes an emailed reset token and stores the new password in one transaction
+func (h *PasswordHandler) resetWithToken(c *gin.Context, token string, password string) (int32, error) {
+	resetToken, err := h.Queries.GetPasswordResetTokenByHash(c, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errResetTokenInvalid
+		}
+		return 0, err
+	}
+
+	hashedPassword, err := utils.Passwords.Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	// Only one concurrent request can consume the token
+	consumed, err := qtx.ConsumePasswordResetToken(c, resetToken.ID)
+	if err != nil {
+		return 0, err
+	}
+	if consumed == 0 {
+		return 0, errResetTokenInvalid
+	}
+
+	err = qtx.UpdateUserPassword(c, repository.UpdateUserPasswordParams{
+		ID:       resetToken.UserID,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := qtx.InvalidateUserPasswordResetTokens(c, resetToken.UserID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return resetToken.UserID, nil
+}
+
+// PUT /v1/user/password
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Compare password
+	if ok, _, err := utils.Passwords.Verify(user.Password, req.CurrentPassword); !ok || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if utils.IsCommonPassword(req.NewPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is too common"})
+		return
+	}
+
+	// Hash password
+	hashedPassword, err := utils.Passwords.Hash(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	err = h.Queries.UpdateUserPassword(c, repository.UpdateUserPasswordParams{
+		ID:       userID,
+		Password: hashedPassword,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// A reset link sent before the change must not undo it
+	if err := h.Queries.InvalidateUserPasswordResetTokens(c, userID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to invalidate password reset tokens")
+	}
+
+	if req.SignOutOtherDevices {
+		if err := revokeOtherUserTokens(c, h.Queries, userID); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to revoke other sessions")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+	}
+
+	h.notifyPasswordChanged(c, user)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// notifyPasswordChanged tells the owner about the change, by email when one is linked, otherwise by SMS
+func (h *PasswordHandler) notifyPasswordChanged(c *gin.Context, user repository.User) {
+	const message = "The password of your TutupLapak account was just changed. If you did not make this change, reset your password immediately."
+
+	if email := utils.NullStringToString(user.Email); email != "" {
+		if err := h.Mailer.SendMail(c, email, "Your password was changed", message); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to send password change email")
+		}
+		return
+	}
+	if phone := utils.NullStringToString(user.Phone); phone != "" {
+		if err := h.SMS.SendSMS(c, phone, message); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to send password change SMS")
+		}
+	}
+}
+
+// Cleanup expired reset tokens periodically
+func (h *PasswordHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			if err := h.Queries.DeleteExpiredPasswordResetTokens(context.Background()); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired password reset tokens")
+			}
+		}
+	}()
+}
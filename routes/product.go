@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"tutuplapak-go/repository"
 	"tutuplapak-go/utils"
@@ -17,23 +20,37 @@ import (
 
 type ProductHandler struct {
 	Queries *repository.Queries
+	DB      *sql.DB
 }
 
-func NewProductHandler(queries *repository.Queries) *ProductHandler {
-	return &ProductHandler{Queries: queries}
+func NewProductHandler(queries *repository.Queries, db *sql.DB) *ProductHandler {
+	return &ProductHandler{Queries: queries, DB: db}
 }
 
 // Request DTO
 type CreateProductRequest struct {
-	Name     string `json:"name" binding:"required,min=4,max=32"`
-	Category string `json:"category" binding:"required,oneof=Food Beverage Clothes Furniture Tools"`
+	Name string `json:"name" binding:"required,min=4,max=32"`
+	// Name of an active category from GET /v1/category
+	Category string `json:"category" binding:"required"`
 	Qty      int32  `json:"qty" binding:"required,min=1"`
 	Price    int32  `json:"price" binding:"required,min=100"`
 	Sku      string `json:"sku" binding:"required,max=32"`
-	FileID   string `json:"fileId" binding:"required"`
+	// Primary image, the first of fileIds when omitted
+	FileID string `json:"fileId" binding:"required_without=FileIDs"`
+	// Gallery in display order, updates without it keep the current gallery
+	FileIDs []string `json:"fileIds" binding:"omitempty,min=1,max=10,dive,required"`
+	// Staff list into the store they work in, the caller's own store is used when omitted
+	StoreID string `json:"storeId"`
 }
 
 // Response DTO
+type ProductImageResponse struct {
+	FileID           string `json:"fileId"`
+	FileURI          string `json:"fileUri"`
+	FileThumbnailURI string `json:"fileThumbnailUri"`
+	IsPrimary        bool   `json:"isPrimary"`
+}
+
 type ProductResponse struct {
 	ProductID        string    `json:"productId"`
 	Name             string    `json:"name"`
@@ -46,6 +63,177 @@ type ProductResponse struct {
 	FileThumbnailURI string    `json:"fileThumbnailUri"`
 	CreatedAt        time.Time `json:"createdAt"`
 	UpdatedAt        time.Time `json:"updatedAt"`
+	// The gallery in display order, fileId is its primary image
+	Images []ProductImageResponse `json:"images"`
+}
+
+// Most images a product's gallery holds
+const maxProductImages = 10
+
+// productImages resolves the gallery of a product from the request in display order, and loads
+// its primary image. current is the gallery kept when the request has no fileIds.
+// It writes the response and returns false on failure.
+func productImages(c *gin.Context, queries *repository.Queries, req CreateProductRequest, current []int32) ([]int32, repository.File, bool) {
+	fileIDs := current
+	if req.FileIDs != nil {
+		fileIDs = make([]int32, 0, len(req.FileIDs))
+		for _, fileIDStr := range req.FileIDs {
+			fileID, err := strconv.Atoi(fileIDStr)
+			if err != nil || slices.Contains(fileIDs, int32(fileID)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "fileIds is not valid"})
+				return nil, repository.File{}, false
+			}
+			fileIDs = append(fileIDs, int32(fileID))
+		}
+	}
+
+	// A primary image outside the gallery is shown first
+	var primaryID int32
+	if req.FileID != "" {
+		fileID, err := strconv.Atoi(req.FileID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+			return nil, repository.File{}, false
+		}
+		primaryID = int32(fileID)
+		if !slices.Contains(fileIDs, primaryID) {
+			fileIDs = append([]int32{primaryID}, fileIDs...)
+		}
+	} else {
+		primaryID = fileIDs[0]
+	}
+	if len(fileIDs) > maxProductImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return nil, repository.File{}, false
+	}
+
+	var primary repository.File
+	for _, fileID := range fileIDs {
+		file, err := queries.GetFileByID(c, fileID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+				return nil, repository.File{}, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return nil, repository.File{}, false
+		}
+		if fileID == primaryID {
+			primary = file
+		}
+	}
+	return fileIDs, primary, true
+}
+
+// saveProductImages replaces the gallery of a product
+func saveProductImages(c *gin.Context, queries *repository.Queries, productID int32, fileIDs []int32, primaryID int32) error {
+	if err := queries.DeleteProductImages(c, productID); err != nil {
+		return err
+	}
+	for i, fileID := range fileIDs {
+		err := queries.CreateProductImage(c, repository.CreateProductImageParams{
+			ProductID: productID,
+			FileID:    fileID,
+			SortOrder: int32(i),
+			IsPrimary: fileID == primaryID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toProductImageResponses(images []repository.ListProductImagesRow) []ProductImageResponse {
+	response := make([]ProductImageResponse, 0, len(images))
+	for _, image := range images {
+		response = append(response, ProductImageResponse{
+			FileID:           strconv.FormatInt(int64(image.FileID), 10),
+			FileURI:          image.FileUri,
+			FileThumbnailURI: image.FileThumnailUri.String,
+			IsPrimary:        image.IsPrimary,
+		})
+	}
+	return response
+}
+
+// productStore resolves the store a new product is listed in and checks the user may
+// manage its products. It writes the response and returns false on failure.
+func productStore(c *gin.Context, queries *repository.Queries, userID int32, storeIDStr string) (repository.Store, bool) {
+	if storeIDStr == "" {
+		store, err := queries.GetOrCreateStore(c, userID)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to get store")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return repository.Store{}, false
+		}
+		return store, true
+	}
+
+	storeID, err := strconv.Atoi(storeIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "storeId is not valid"})
+		return repository.Store{}, false
+	}
+	store, err := queries.GetStoreByID(c, int32(storeID))
+	var member, allowed bool
+	if err == nil {
+		member, allowed, err = canManageProducts(c, queries, store, userID)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || err == nil && !member:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "storeId is not valid"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+	case !allowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	default:
+		return store, true
+	}
+	return repository.Store{}, false
+}
+
+// authorizeProduct loads an active product and checks the user may manage the products
+// of its store. Products of stores the user does not work in are reported as not found.
+// It writes the response and returns false on failure.
+func authorizeProduct(c *gin.Context, queries *repository.Queries, userID int32, productIDStr string) (repository.Product, bool) {
+	productID, err := strconv.Atoi(productIDStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+		return repository.Product{}, false
+	}
+	product, err := queries.GetProductByID(c, int32(productID))
+	if err == nil && !product.StoreID.Valid {
+		err = sql.ErrNoRows
+	}
+	var store repository.Store
+	if err == nil {
+		store, err = queries.GetStoreByID(c, product.StoreID.Int32)
+	}
+	var member, allowed bool
+	if err == nil {
+		member, allowed, err = canManageProducts(c, queries, store, userID)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || err == nil && !member:
+		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+	case !allowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	default:
+		return product, true
+	}
+	return repository.Product{}, false
+}
+
+// canManageProducts reports whether the user works in the store and may manage its products
+func canManageProducts(c *gin.Context, queries *repository.Queries, store repository.Store, userID int32) (bool, bool, error) {
+	permissions, member, err := storePermissions(c, queries, store, userID)
+	if err != nil || !member {
+		return false, false, err
+	}
+	return true, slices.Contains(permissions, utils.StorePermissionManageProducts), nil
 }
 
 // POST /v1/product
@@ -63,27 +251,15 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Validate file ID
-	fileIDInt, err := strconv.Atoi(req.FileID)
-	if err != nil {
-		utils.Logger.Error().Err(err).Msg("Invalid file id")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
-		return
-	}
-
-	// Check if file exists
-	file, err := h.Queries.GetFileByID(c, int32(fileIDInt))
-	if err != nil {
-		utils.Logger.Error().Err(err).Msg("File not found")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+	// Validate the gallery, every file must exist
+	fileIDs, file, ok := productImages(c, h.Queries, req, nil)
+	if !ok {
 		return
 	}
 
 	// Get category ID from category name
-	categoryID, err := h.Queries.GetProductCategoryByName(c, sql.NullString{String: req.Category, Valid: true})
-	if err != nil {
-		utils.Logger.Error().Err(err).Msg("Invalid category")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+	productCategory, ok := resolveProductCategory(c, h.Queries, req.Category)
+	if !ok {
 		return
 	}
 
@@ -94,11 +270,16 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Check if SKU already exists for this user
+	store, ok := productStore(c, h.Queries, userID, req.StoreID)
+	if !ok {
+		return
+	}
+
+	// Check if SKU already exists in the store, products stay keyed by the store owner
 	if req.Sku != "" {
 		_, err = h.Queries.GetProductBySKUAndUserID(c, repository.GetProductBySKUAndUserIDParams{
 			Sku:    sql.NullString{String: req.Sku, Valid: true},
-			UserID: sql.NullInt32{Int32: userID, Valid: true},
+			UserID: sql.NullInt32{Int32: store.OwnerID, Valid: true},
 		})
 		if err == nil {
 			utils.Logger.Error().Msg("SKU already exists for this user")
@@ -107,15 +288,24 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		}
 	}
 
-	// Create product
-	product, err := h.Queries.CreateProduct(c, repository.CreateProductParams{
-		UserID:   sql.NullInt32{Int32: userID, Valid: true},
+	// Create product together with its gallery
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	product, err := qtx.CreateProduct(c, repository.CreateProductParams{
+		UserID:   sql.NullInt32{Int32: store.OwnerID, Valid: true},
+		StoreID:  sql.NullInt32{Int32: store.ID, Valid: true},
 		Name:     sql.NullString{String: req.Name, Valid: true},
-		Category: sql.NullInt32{Int32: categoryID, Valid: true},
+		Category: sql.NullInt32{Int32: productCategory.ProductCategoryID, Valid: true},
 		Qty:      sql.NullInt32{Int32: req.Qty, Valid: true},
 		Price:    sql.NullString{String: fmt.Sprintf("%d", req.Price), Valid: true},
 		Sku:      sql.NullString{String: req.Sku, Valid: true},
-		FileID:   sql.NullInt32{Int32: int32(fileIDInt), Valid: true},
+		FileID:   sql.NullInt32{Int32: file.ID, Valid: true},
 	})
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("Failed to create product")
@@ -127,6 +317,26 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
+	if err := saveProductImages(c, qtx, product.ProductID, fileIDs, file.ID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to save product images")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Listing a first product turns a buyer into a seller
+	if err := h.Queries.PromoteToSeller(c, store.OwnerID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to promote user to seller")
+	}
+
+	images, err := h.Queries.ListProductImages(c, product.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
 
 	// Build response
 	response := ProductResponse{
@@ -141,6 +351,7 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		FileThumbnailURI: file.FileThumnailUri.String,
 		CreatedAt:        product.CreatedAt.Time,
 		UpdatedAt:        product.UpdatedAt.Time,
+		Images:           toProductImageResponses(images),
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -159,10 +370,52 @@ type GetProductResponse struct {
 	FileThumbnailURI string    `json:"fileThumbnailUri"`
 	CreatedAt        time.Time `json:"createdAt"`
 	UpdatedAt        time.Time `json:"updatedAt"`
+	// The gallery in display order, fileId is its primary image
+	Images []ProductImageResponse `json:"images"`
+	// Products with variants are bought through one of them
+	Variants []VariantResponse `json:"variants"`
 }
 
 // GET /v1/product
 func (h *ProductHandler) GetProducts(c *gin.Context) {
+	listProducts(c, h.Queries, sql.NullInt32{})
+}
+
+// GET /v1/user/products
+// Lists the caller's own products, e.g. for a POS integration holding a products:read key.
+func (h *ProductHandler) GetOwnProducts(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	listProducts(c, h.Queries, sql.NullInt32{Int32: userID, Valid: true})
+}
+
+// productCursor is the position of the last product of a page, only the fields of its sortBy are used
+type productCursor struct {
+	SortBy    string    `json:"s"`
+	ID        int32     `json:"id"`
+	CreatedAt time.Time `json:"t"`
+	Price     string    `json:"p,omitempty"`
+	Relevance float64   `json:"r,omitempty"`
+}
+
+// ProductPageResponse is the listing answered to requests that paginate with a cursor
+type ProductPageResponse struct {
+	Data       []GetProductResponse `json:"data"`
+	NextCursor *string              `json:"nextCursor"`
+	Total      *int64               `json:"total,omitempty"`
+}
+
+// Longer search queries are rejected rather than sent to the full-text search
+const maxSearchQueryLength = 100
+
+// listProducts answers a product listing from the query string filters,
+// limited to the products of sellerID when it is set. Pages are addressed by
+// limit and offset, or by cursor which answers a ProductPageResponse.
+func listProducts(c *gin.Context, queries *repository.Queries, sellerID sql.NullInt32) {
 	// Default values for limit and offset
 	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
 	if err != nil || limit < 0 {
@@ -200,8 +453,8 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var category sql.NullString
 	categoryStr := c.Query("category")
 	if categoryStr != "" {
-		// Validate that the category exists in the database
-		_, err := h.Queries.GetProductCategoryByName(c, sql.NullString{String: categoryStr, Valid: true})
+		// Validate that the category exists in the database, its subcategories are included
+		_, err := queries.GetProductCategoryByName(c, categoryStr)
 		if err != nil {
 			// If no rows are returned, the category is invalid
 			if errors.Is(err, sql.ErrNoRows) {
@@ -215,35 +468,162 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		category = sql.NullString{String: categoryStr, Valid: true}
 	}
 
-	// sortBy filter
-	var sortBy sql.NullString
+	// q searches names, SKUs and categories, tolerating typos in the name
+	var q sql.NullString
+	qStr := strings.TrimSpace(c.Query("q"))
+	if qStr != "" {
+		if utf8.RuneCountInString(qStr) > maxSearchQueryLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is too long"})
+			return
+		}
+		q = sql.NullString{String: qStr, Valid: true}
+	}
+
+	// sortBy filter, without one results are ranked by relevance when searching and newest first otherwise
+	sortBy := "newest"
+	if q.Valid {
+		sortBy = "relevance"
+	}
 	sortByStr := c.Query("sortBy")
 	validSorts := []string{"newest", "oldest", "cheapest", "expensive"}
 	for _, s := range validSorts {
 		if sortByStr == s {
-			sortBy = sql.NullString{String: sortByStr, Valid: true}
+			sortBy = sortByStr
 			break
 		}
 	}
 
-	// Call repository to get products
-	products, err := h.Queries.ListProducts(c, repository.ListProductsParams{
+	// A cursor continues after the last product of the previous page, offset is then ignored.
+	// Passing an empty cursor asks for the first page with the paginated response.
+	cursorStr, cursorMode := c.GetQuery("cursor")
+	params := repository.ListProductsParams{
 		ProductID: productID,
 		Sku:       sku,
 		Category:  category,
+		SellerID:  sellerID,
+		Q:         q,
 		SortBy:    sortBy,
-		Limit:     int32(limit),
-		Offset:    int32(offset),
-	})
+		// One more than asked tells whether there is a next page
+		Limit:  int32(limit) + 1,
+		Offset: int32(offset),
+	}
+	if cursorStr != "" {
+		var cursor productCursor
+		if err := utils.DecodeCursor(cursorStr, &cursor); err != nil || cursor.SortBy != sortBy {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		params.CursorID = sql.NullInt32{Int32: cursor.ID, Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorPrice = sql.NullString{String: cursor.Price, Valid: cursor.Price != ""}
+		params.CursorRelevance = sql.NullFloat64{Float64: cursor.Relevance, Valid: true}
+	}
+	if cursorMode {
+		params.Offset = 0
+	}
+
+	// Call repository to get products
+	products, err := queries.ListProducts(c, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	hasMore := len(products) > limit
+	if hasMore {
+		products = products[:limit]
+	}
+
+	var total *int64
+	if c.Query("includeTotal") == "true" {
+		count, err := queries.CountProducts(c, repository.CountProductsParams{
+			ProductID: productID,
+			Sku:       sku,
+			Category:  category,
+			SellerID:  sellerID,
+			Q:         q,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		total = &count
+	}
+
+	// RFC 8288 links to the neighbouring pages
+	links := map[string]url.Values{}
+	var nextCursor *string
+	if cursorMode {
+		if hasMore && len(products) > 0 {
+			last := products[len(products)-1]
+			next, err := utils.EncodeCursor(productCursor{
+				SortBy:    sortBy,
+				ID:        last.ProductID,
+				CreatedAt: last.CreatedAt.Time,
+				Price:     last.Price.String,
+				Relevance: last.Relevance,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+				return
+			}
+			nextCursor = &next
+			links["next"] = pageQuery(c, "cursor", next)
+		}
+		links["first"] = pageQuery(c, "cursor", "")
+	} else {
+		if hasMore {
+			links["next"] = pageQuery(c, "offset", strconv.Itoa(offset+limit))
+		}
+		if offset > 0 {
+			links["prev"] = pageQuery(c, "offset", strconv.Itoa(max(offset-limit, 0)))
+			links["first"] = pageQuery(c, "offset", "0")
+		}
+		if total != nil {
+			c.Header("X-Total-Count", strconv.FormatInt(*total, 10))
+		}
+	}
+	setLinkHeader(c, links)
+
+	// Variants of the whole page at once
+	productIDs := make([]int32, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ProductID)
+	}
+	variants, err := queries.ListProductVariantsByProductIDs(c, productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	productVariants := make(map[int32][]repository.ProductVariant)
+	for _, variant := range variants {
+		productVariants[variant.ProductID] = append(productVariants[variant.ProductID], variant)
+	}
+	images, err := queries.ListProductImagesByProductIDs(c, productIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
+	galleries := make(map[int32][]repository.ListProductImagesRow)
+	for _, image := range images {
+		galleries[image.ProductID] = append(galleries[image.ProductID], repository.ListProductImagesRow{
+			FileID:          image.FileID,
+			SortOrder:       image.SortOrder,
+			IsPrimary:       image.IsPrimary,
+			FileUri:         image.FileUri,
+			FileThumnailUri: image.FileThumnailUri,
+		})
+	}
 
 	// Build response
 	var response []GetProductResponse
 	for _, p := range products {
-		fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, p.FileID)
+		fileURI, fileThumbnailURI, err := utils.GetFileInfo(queries, c, p.FileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error fetching file info"})
+			return
+		}
+
+		variantResponses, err := toVariantResponses(c, queries, productVariants[p.ProductID], p.Price, p.FileID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error fetching file info"})
 			return
@@ -253,7 +633,7 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		response = append(response, GetProductResponse{
 			ProductID:        fmt.Sprintf("%d", p.ProductID),
 			Name:             utils.NullStringToString(p.Name),
-			Category:         utils.NullStringToString(p.CategoryName),
+			Category:         p.CategoryName,
 			Qty:              p.Qty.Int32,
 			Price:            int32(priceInt),
 			Sku:              utils.NullStringToString(p.Sku),
@@ -262,6 +642,8 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 			FileThumbnailURI: fileThumbnailURI,
 			CreatedAt:        p.CreatedAt.Time,
 			UpdatedAt:        p.UpdatedAt.Time,
+			Images:           toProductImageResponses(galleries[p.ProductID]),
+			Variants:         variantResponses,
 		})
 	}
 
@@ -270,27 +652,53 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		response = make([]GetProductResponse, 0)
 	}
 
+	if cursorMode {
+		c.JSON(http.StatusOK, ProductPageResponse{
+			Data:       response,
+			NextCursor: nextCursor,
+			Total:      total,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
+// pageQuery returns the request's query string with key set to value
+func pageQuery(c *gin.Context, key, value string) url.Values {
+	query := c.Request.URL.Query()
+	query.Set(key, value)
+	return query
+}
+
+// setLinkHeader writes an RFC 8288 Link header with one relative link per relation
+func setLinkHeader(c *gin.Context, links map[string]url.Values) {
+	rels := make([]string, 0, len(links))
+	for rel := range links {
+		rels = append(rels, rel)
+	}
+	slices.Sort(rels)
+
+	values := make([]string, 0, len(rels))
+	for _, rel := range rels {
+		values = append(values, fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, links[rel].Encode(), rel))
+	}
+	if len(values) > 0 {
+		c.Header("Link", strings.Join(values, ", "))
+	}
+}
+
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	productID, err := strconv.Atoi(c.Param("productId"))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
-		return
-	}
-
-	// Check if product exists and belongs to user
-	existingProduct, err := h.Queries.GetProductByID(c, int32(productID))
-	if err != nil || existingProduct.UserID.Int32 != userID {
-		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+	// Check if product exists and the user may manage the products of its store
+	existingProduct, ok := authorizeProduct(c, h.Queries, userID, c.Param("productId"))
+	if !ok {
 		return
 	}
+	productID := existingProduct.ProductID
 
 	var req CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -304,48 +712,57 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// Validate file ID
-	fileIDInt, err := strconv.Atoi(req.FileID)
+	// Validate the gallery, without fileIds the current one is kept
+	currentImages, err := h.Queries.ListProductImages(c, productID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
-	file, err := h.Queries.GetFileByID(c, int32(fileIDInt))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid / exists"})
+	currentFileIDs := make([]int32, 0, len(currentImages))
+	for _, image := range currentImages {
+		currentFileIDs = append(currentFileIDs, image.FileID)
+	}
+	fileIDs, file, ok := productImages(c, h.Queries, req, currentFileIDs)
+	if !ok {
 		return
 	}
 
 	// Get category ID from category name
-	categoryID, err := h.Queries.GetProductCategoryByName(c, sql.NullString{String: req.Category, Valid: true})
-	if err != nil {
-		utils.Logger.Error().Err(err).Msg("Invalid category")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category"})
+	productCategory, ok := resolveProductCategory(c, h.Queries, req.Category)
+	if !ok {
 		return
 	}
 
-	// Check if SKU already exists for this user (excluding current product)
+	// Check if SKU already exists in the store (excluding current product)
 	if req.Sku != "" && req.Sku != existingProduct.Sku.String {
 		existingSku, err := h.Queries.GetProductBySKUAndUserID(c, repository.GetProductBySKUAndUserIDParams{
 			Sku:    sql.NullString{String: req.Sku, Valid: true},
-			UserID: sql.NullInt32{Int32: userID, Valid: true},
+			UserID: existingProduct.UserID,
 		})
-		if err == nil && existingSku.ProductID != int32(productID) {
+		if err == nil && existingSku.ProductID != productID {
 			utils.Logger.Error().Msg("SKU already exists for this user")
 			c.JSON(http.StatusConflict, gin.H{"error": "sku already exists (per account basis)"})
 			return
 		}
 	}
 
-	updatedProduct, err := h.Queries.UpdateProduct(c, repository.UpdateProductParams{
-		ProductID: int32(productID),
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	updatedProduct, err := qtx.UpdateProduct(c, repository.UpdateProductParams{
+		ProductID: productID,
 		Name:      sql.NullString{String: req.Name, Valid: true},
-		Category:  sql.NullInt32{Int32: categoryID, Valid: true},
+		Category:  sql.NullInt32{Int32: productCategory.ProductCategoryID, Valid: true},
 		Qty:       sql.NullInt32{Int32: req.Qty, Valid: true},
 		Price:     sql.NullString{String: fmt.Sprintf("%d", req.Price), Valid: true},
 		Sku:       sql.NullString{String: req.Sku, Valid: true},
-		FileID:    sql.NullInt32{Int32: int32(fileIDInt), Valid: true},
-		UserID:    sql.NullInt32{Int32: userID, Valid: true},
+		FileID:    sql.NullInt32{Int32: file.ID, Valid: true},
+		StoreID:   existingProduct.StoreID,
 	})
 	if err != nil {
 		// Check if it's a unique constraint violation for SKU
@@ -356,6 +773,21 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error while updating"})
 		return
 	}
+	if err := saveProductImages(c, qtx, productID, fileIDs, file.ID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to save product images")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error while updating"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error while updating"})
+		return
+	}
+
+	images, err := h.Queries.ListProductImages(c, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
 
 	// Build response
 	response := ProductResponse{
@@ -370,6 +802,7 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		FileThumbnailURI: file.FileThumnailUri.String,
 		CreatedAt:        updatedProduct.CreatedAt.Time,
 		UpdatedAt:        updatedProduct.UpdatedAt.Time,
+		Images:           toProductImageResponses(images),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -382,26 +815,36 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	productID, err := strconv.Atoi(c.Param("productId"))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+	product, ok := authorizeProduct(c, h.Queries, userID, c.Param("productId"))
+	if !ok {
 		return
 	}
 
-	product, err := h.Queries.GetProductByID(c, int32(productID))
-	if err != nil || product.UserID.Int32 != userID {
-		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
 
-	err = h.Queries.DeleteProduct(c, repository.DeleteProductParams{
-		ProductID: int32(productID),
-		UserID:    sql.NullInt32{Int32: userID, Valid: true},
+	err = qtx.DeleteProduct(c, repository.DeleteProductParams{
+		ProductID: product.ProductID,
+		StoreID:   product.StoreID,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
+	// The variants go with the product, otherwise their SKUs stay taken for good
+	if err := qtx.DeactivateProductVariants(c, product.ProductID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
 
 	c.Status(http.StatusOK)
 }
@@ -2,9 +2,12 @@ package routes
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"tutuplapak-go/provider"
 	"tutuplapak-go/repository"
 	"tutuplapak-go/utils"
 
@@ -13,18 +16,25 @@ import (
 
 type ProfileHandler struct {
 	Queries *repository.Queries
+	Mailer  provider.Mailer
+	SMS     provider.SMSSender
 }
 
-func NewProfileHandler(queries *repository.Queries) *ProfileHandler {
-	return &ProfileHandler{Queries: queries}
+func NewProfileHandler(queries *repository.Queries, mailer provider.Mailer, sms provider.SMSSender) *ProfileHandler {
+	return &ProfileHandler{Queries: queries, Mailer: mailer, SMS: sms}
 }
 
 // Request structs
 type UpdateProfileRequest struct {
-	FileID            string `json:"fileId"`
-	BankAccountName   string `json:"bankAccountName" binding:"required,min=4,max=32"`
+	FileID string `json:"fileId"`
+	// Shown on the public storefront, kept when omitted
+	StoreName *string `json:"storeName" binding:"omitempty,max=64"`
+	// One of GET /v1/banks, the account number is validated against its rules
+	BankCode          string `json:"bankCode" binding:"required"`
 	BankAccountHolder string `json:"bankAccountHolder" binding:"required,min=4,max=32"`
-	BankAccountNumber string `json:"bankAccountNumber" binding:"required,min=4,max=32"`
+	BankAccountNumber string `json:"bankAccountNumber" binding:"required,max=32"`
+	// Required to change bank details once two-factor authentication is enabled
+	TotpCode string `json:"totpCode"`
 }
 
 type LinkPhoneRequest struct {
@@ -39,12 +49,15 @@ type LinkEmailRequest struct {
 type ProfileResponse struct {
 	Email             string `json:"email"`
 	Phone             string `json:"phone"`
+	StoreName         string `json:"storeName"`
 	FileID            string `json:"fileId"`
 	FileURI           string `json:"fileUri"`
 	FileThumbnailURI  string `json:"fileThumbnailUri"`
+	BankCode          string `json:"bankCode"`
 	BankAccountName   string `json:"bankAccountName"`
 	BankAccountHolder string `json:"bankAccountHolder"`
 	BankAccountNumber string `json:"bankAccountNumber"`
+	PendingEmail      string `json:"pendingEmail,omitempty"`
 }
 
 // Helper function to get user ID from gin context
@@ -56,6 +69,38 @@ func getUserIDFromContext(c *gin.Context) (int32, error) {
 	return userID.(int32), nil
 }
 
+// profileResponse builds the profile of a user, the bank details are those of the default account
+func profileResponse(c *gin.Context, queries *repository.Queries, userID int32, email, phone, storeName sql.NullString, fileID sql.NullInt32) (ProfileResponse, error) {
+	fileURI, fileThumbnailURI, err := utils.GetFileInfo(queries, c, fileID)
+	if err != nil {
+		return ProfileResponse{}, err
+	}
+
+	response := ProfileResponse{
+		Email:            utils.NullStringToString(email),
+		Phone:            utils.NullStringToString(phone),
+		StoreName:        utils.NullStringToString(storeName),
+		FileID:           utils.NullInt32ToString(fileID),
+		FileURI:          fileURI,
+		FileThumbnailURI: fileThumbnailURI,
+	}
+	account, err := queries.GetDefaultBankAccount(c, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ProfileResponse{}, err
+	}
+	if err == nil {
+		account, err = openBankAccount(account)
+		if err != nil {
+			return ProfileResponse{}, err
+		}
+		response.BankCode = utils.NullStringToString(account.BankCode)
+		response.BankAccountName = account.BankAccountName
+		response.BankAccountHolder = account.BankAccountHolder
+		response.BankAccountNumber = account.BankAccountNumber
+	}
+	return response, nil
+}
+
 // GET /v1/user
 func (h *ProfileHandler) GetProfile(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
@@ -70,22 +115,11 @@ func (h *ProfileHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, user.FileID)
+	response, err := profileResponse(c, h.Queries, userID, user.Email, user.Phone, user.StoreName, user.FileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
-
-	response := ProfileResponse{
-		Email:             utils.NullStringToString(user.Email),
-		Phone:             utils.NullStringToString(user.Phone),
-		FileID:            utils.NullInt32ToString(user.FileID),
-		FileURI:           fileURI,
-		FileThumbnailURI:  fileThumbnailURI,
-		BankAccountName:   utils.NullStringToString(user.BankAccountName),
-		BankAccountHolder: utils.NullStringToString(user.BankAccountHolder),
-		BankAccountNumber: utils.NullStringToString(user.BankAccountNumber),
-	}
 	c.JSON(http.StatusOK, response)
 }
 
@@ -127,13 +161,86 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	bank, accountNumber, ok := resolveBankAccount(c, h.Queries, req.BankCode, req.BankAccountNumber)
+	if !ok {
+		return
+	}
+
+	// The bank details of the profile are the default account's, payments go to
+	// them, so changing them needs a fresh second factor
+	current, err := h.Queries.GetDefaultBankAccount(c, userID)
+	hasDefault := err == nil
+	if hasDefault {
+		current, err = openBankAccount(current)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	bankChanged := !hasDefault ||
+		current.BankCode.String != bank.Code ||
+		current.BankAccountHolder != req.BankAccountHolder ||
+		current.BankAccountNumber != accountNumber
+	var sealedNumber string
+	var numberIndex sql.NullString
+	if bankChanged {
+		sealedNumber, numberIndex, ok = checkBankAccountNumber(c, h.Queries, userID, current.ID, bank.Code, accountNumber)
+		if !ok {
+			return
+		}
+	}
+	if bankChanged && !requireFreshTOTP(c, h.Queries, userID, req.TotpCode) {
+		return
+	}
+
+	if bankChanged && hasDefault {
+		_, err = h.Queries.UpdateBankAccount(c, repository.UpdateBankAccountParams{
+			ID:                    current.ID,
+			UserID:                userID,
+			BankCode:              sql.NullString{String: bank.Code, Valid: true},
+			BankAccountName:       bank.Name,
+			BankAccountHolder:     req.BankAccountHolder,
+			BankAccountNumber:     sealedNumber,
+			BankAccountNumberBidx: numberIndex,
+			IsEnabled:             true,
+		})
+	} else if bankChanged {
+		// Without a default there is no enabled account, the new one becomes the default
+		var store repository.Store
+		store, err = h.Queries.GetOrCreateStore(c, userID)
+		if err == nil {
+			_, err = h.Queries.CreateBankAccount(c, repository.CreateBankAccountParams{
+				UserID:                userID,
+				StoreID:               sql.NullInt32{Int32: store.ID, Valid: true},
+				BankCode:              sql.NullString{String: bank.Code, Valid: true},
+				BankAccountName:       bank.Name,
+				BankAccountHolder:     req.BankAccountHolder,
+				BankAccountNumber:     sealedNumber,
+				BankAccountNumberBidx: numberIndex,
+				IsEnabled:             true,
+			})
+		}
+		if err == nil {
+			err = h.Queries.EnsureDefaultBankAccount(c, userID)
+		}
+	}
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to save default bank account")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// An omitted storeName keeps the current one
+	var storeName sql.NullString
+	if req.StoreName != nil {
+		storeName = sql.NullString{String: strings.TrimSpace(*req.StoreName), Valid: true}
+	}
+
 	// Update user profile
 	updatedUser, err := h.Queries.UpdateUserProfile(c, repository.UpdateUserProfileParams{
-		ID:                userID,
-		FileID:            fileID,
-		BankAccountName:   sql.NullString{String: req.BankAccountName, Valid: true},
-		BankAccountHolder: sql.NullString{String: req.BankAccountHolder, Valid: true},
-		BankAccountNumber: sql.NullString{String: req.BankAccountNumber, Valid: true},
+		ID:        userID,
+		FileID:    fileID,
+		StoreName: storeName,
 	})
 	if err != nil {
 		utils.Logger.Error().Err(err).Msg("Failed to update user profile")
@@ -141,23 +248,11 @@ func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, updatedUser.FileID)
+	response, err := profileResponse(c, h.Queries, userID, updatedUser.Email, updatedUser.Phone, updatedUser.StoreName, updatedUser.FileID)
 	if err != nil {
-		utils.Logger.Error().Err(err).Msg("Failed to get file info")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
-
-	response := ProfileResponse{
-		Email:             utils.NullStringToString(updatedUser.Email),
-		Phone:             utils.NullStringToString(updatedUser.Phone),
-		FileID:            utils.NullInt32ToString(updatedUser.FileID),
-		FileURI:           fileURI,
-		FileThumbnailURI:  fileThumbnailURI,
-		BankAccountName:   utils.NullStringToString(updatedUser.BankAccountName),
-		BankAccountHolder: utils.NullStringToString(updatedUser.BankAccountHolder),
-		BankAccountNumber: utils.NullStringToString(updatedUser.BankAccountNumber),
-	}
 	c.JSON(http.StatusOK, response)
 }
 
@@ -175,18 +270,39 @@ func (h *ProfileHandler) LinkPhone(c *gin.Context) {
 		return
 	}
 
-	// Validate phone format
-	if !utils.ValidatePhone(req.Phone) {
+	// Validate phone format, phones are stored and looked up in E.164
+	phone, ok := utils.NormalizePhone(req.Phone)
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
 		return
 	}
+	req.Phone = phone
 
-	// Check if phone is already taken
-	_, err = h.Queries.GetUserByPhone(c, sql.NullString{String: req.Phone, Valid: true})
-	if err == nil {
+	// Check if phone is already taken, only a number verified by another account is
+	holder, err := h.Queries.GetPhoneHolder(c, sql.NullString{String: req.Phone, Valid: true})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err == nil && (holder.ID == userID || holder.PhoneVerifiedAt.Valid) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Phone is taken"})
 		return
 	}
+	if err == nil {
+		// Another account claims the number without having verified it. Taking it over needs
+		// proof, the phone is linked by POST /v1/otp/phone/verify.
+		err = sendPhoneOTP(c, h.Queries, h.SMS, sql.NullInt32{Int32: userID, Valid: true}, req.Phone,
+			utils.OTPPurposeVerifyPhone, verifyPhoneMessage)
+		if err != nil {
+			writeOTPError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":   "Verification code sent",
+			"expiresIn": int(utils.OTPTTL.Seconds()),
+		})
+		return
+	}
 
 	// Link phone to user
 	updatedUser, err := h.Queries.LinkPhoneToUser(c, repository.LinkPhoneToUserParams{
@@ -198,22 +314,11 @@ func (h *ProfileHandler) LinkPhone(c *gin.Context) {
 		return
 	}
 
-	fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, updatedUser.FileID)
+	response, err := profileResponse(c, h.Queries, userID, updatedUser.Email, updatedUser.Phone, updatedUser.StoreName, updatedUser.FileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
-
-	response := ProfileResponse{
-		Email:             utils.NullStringToString(updatedUser.Email),
-		Phone:             utils.NullStringToString(updatedUser.Phone),
-		FileID:            utils.NullInt32ToString(updatedUser.FileID),
-		FileURI:           fileURI,
-		FileThumbnailURI:  fileThumbnailURI,
-		BankAccountName:   utils.NullStringToString(updatedUser.BankAccountName),
-		BankAccountHolder: utils.NullStringToString(updatedUser.BankAccountHolder),
-		BankAccountNumber: utils.NullStringToString(updatedUser.BankAccountNumber),
-	}
 	c.JSON(http.StatusOK, response)
 }
 
@@ -238,31 +343,33 @@ func (h *ProfileHandler) LinkEmail(c *gin.Context) {
 		return
 	}
 
-	// Link email to user
-	updatedUser, err := h.Queries.LinkEmailToUser(c, repository.LinkEmailToUserParams{
-		ID:    userID,
-		Email: sql.NullString{String: req.Email, Valid: true},
-	})
+	user, err := h.Queries.GetUserByID(c, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
 
-	fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, updatedUser.FileID)
+	// The address only becomes the account's email once it confirms, until then it
+	// can not block its owner from registering or be matched to this account
+	err = h.Queries.SetPendingEmail(c, repository.SetPendingEmailParams{
+		ID:           userID,
+		PendingEmail: sql.NullString{String: req.Email, Valid: true},
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
 		return
 	}
+	if err := sendEmailLink(c, h.Mailer, userID, req.Email, utils.LinkPurposeChangeEmail); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to send email confirmation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
 
-	response := ProfileResponse{
-		Email:             utils.NullStringToString(updatedUser.Email),
-		Phone:             utils.NullStringToString(updatedUser.Phone),
-		FileID:            utils.NullInt32ToString(updatedUser.FileID),
-		FileURI:           fileURI,
-		FileThumbnailURI:  fileThumbnailURI,
-		BankAccountName:   utils.NullStringToString(updatedUser.BankAccountName),
-		BankAccountHolder: utils.NullStringToString(updatedUser.BankAccountHolder),
-		BankAccountNumber: utils.NullStringToString(updatedUser.BankAccountNumber),
+	response, err := profileResponse(c, h.Queries, userID, user.Email, user.Phone, user.StoreName, user.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
 	}
-	c.JSON(http.StatusOK, response)
+	response.PendingEmail = req.Email
+	c.JSON(http.StatusAccepted, response)
 }
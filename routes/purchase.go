@@ -3,6 +3,7 @@ package routes
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -24,6 +25,8 @@ func NewPurchaseHandler(queries *repository.Queries, db *sql.DB) *PurchaseHandle
 // Request structs
 type PurchasedItemRequest struct {
 	ProductID string `json:"productId" binding:"required"`
+	// Required for products with variants
+	VariantID string `json:"variantId"`
 	Qty       int32  `json:"qty" binding:"required,min=1"`
 }
 
@@ -36,24 +39,38 @@ type CreatePurchaseRequest struct {
 
 // Response structs
 type PurchasedItemResponse struct {
-	ProductID        string `json:"productId"`
-	Name             string `json:"name"`
-	Category         string `json:"category"`
-	Qty              int32  `json:"qty"`
-	Price            int    `json:"price"`
-	SKU              string `json:"sku"`
-	FileID           string `json:"fileId"`
-	FileURI          string `json:"fileUri"`
-	FileThumbnailURI string `json:"fileThumbnailUri"`
-	CreatedAt        string `json:"createdAt"`
-	UpdatedAt        string `json:"updatedAt"`
+	ProductID        string            `json:"productId"`
+	VariantID        string            `json:"variantId"`
+	Options          map[string]string `json:"options,omitempty"`
+	Name             string            `json:"name"`
+	Category         string            `json:"category"`
+	Qty              int32             `json:"qty"`
+	Price            int               `json:"price"`
+	SKU              string            `json:"sku"`
+	FileID           string            `json:"fileId"`
+	FileURI          string            `json:"fileUri"`
+	FileThumbnailURI string            `json:"fileThumbnailUri"`
+	CreatedAt        string            `json:"createdAt"`
+	UpdatedAt        string            `json:"updatedAt"`
 }
 
+type PaymentAccountResponse struct {
+	BankAccountID     string `json:"bankAccountId"`
+	BankCode          string `json:"bankCode"`
+	BankAccountName   string `json:"bankAccountName"`
+	BankAccountHolder string `json:"bankAccountHolder"`
+	BankAccountNumber string `json:"bankAccountNumber"`
+	IsDefault         bool   `json:"isDefault"`
+}
+
+// PaymentDetailResponse is what a buyer pays one seller. The top level bank
+// details are the seller's default account, BankAccounts lists every enabled one.
 type PaymentDetailResponse struct {
-	BankAccountName   string  `json:"bankAccountName"`
-	BankAccountHolder string  `json:"bankAccountHolder"`
-	BankAccountNumber string  `json:"bankAccountNumber"`
-	TotalPrice        float64 `json:"totalPrice"`
+	BankAccountName   string                   `json:"bankAccountName"`
+	BankAccountHolder string                   `json:"bankAccountHolder"`
+	BankAccountNumber string                   `json:"bankAccountNumber"`
+	BankAccounts      []PaymentAccountResponse `json:"bankAccounts"`
+	TotalPrice        float64                  `json:"totalPrice"`
 }
 
 type CreatePurchaseResponse struct {
@@ -82,16 +99,21 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 			return
 		}
 	} else if req.SenderContactType == "phone" {
-		if !utils.ValidatePhone(req.SenderContactDetail) {
+		phone, ok := utils.NormalizePhone(req.SenderContactDetail)
+		if !ok {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number format"})
 			return
 		}
+		req.SenderContactDetail = phone
 	}
 
 	ctx := context.Background()
 
 	var productSnapshots []repository.Product
-	sellerSubtotals := make(map[int32]float64)
+	// The zero value for items bought without a variant
+	var variantSnapshots []repository.ProductVariant
+	// Each store is paid separately, into its own bank accounts
+	storeSubtotals := make(map[int32]float64)
 	var overallTotalPrice float64
 	var total int32
 
@@ -118,23 +140,69 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 			return
 		}
 
-		if product.Qty.Int32 < item.Qty {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Not enough stock for product %s. Available: %d, Requested: %d", product.Name.String, product.Qty.Int32, item.Qty)})
-			return
+		// Products with variants keep their stock per variant
+		var variant repository.ProductVariant
+		if item.VariantID != "" {
+			variantID, err := strconv.Atoi(item.VariantID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID format"})
+				return
+			}
+			variant, err = h.Queries.GetProductVariant(ctx, repository.GetProductVariantParams{
+				ID:        int32(variantID),
+				ProductID: product.ProductID,
+			})
+			if err != nil {
+				if err == sql.ErrNoRows {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Variant with ID %s not found", item.VariantID)})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product variant"})
+				return
+			}
+			if variant.Qty < item.Qty {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Not enough stock for product %s (%s). Available: %d, Requested: %d", product.Name.String, variant.Sku, variant.Qty, item.Qty)})
+				return
+			}
+		} else {
+			variantCount, err := h.Queries.CountProductVariants(ctx, product.ProductID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product variants"})
+				return
+			}
+			if variantCount > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variantId is required for product %s", item.ProductID)})
+				return
+			}
+			if product.Qty.Int32 < item.Qty {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Not enough stock for product %s. Available: %d, Requested: %d", product.Name.String, product.Qty.Int32, item.Qty)})
+				return
+			}
 		}
 
 		productSnapshots = append(productSnapshots, product)
-		price, _ := strconv.ParseFloat(product.Price.String, 64)
+		variantSnapshots = append(variantSnapshots, variant)
+		price, _ := strconv.ParseFloat(variantPrice(variant, product.Price).String, 64)
 		itemTotal := price * float64(item.Qty)
 		overallTotalPrice += itemTotal
-		sellerSubtotals[product.UserID.Int32] += itemTotal
+		storeSubtotals[product.StoreID.Int32] += itemTotal
 		total += int32(itemTotal)
 	}
 
+	// The buyer's contact is personal data, it is stored encrypted
+	sealedContact, err := utils.DataKeys.Encrypt(utils.FieldSenderContactDetail, req.SenderContactDetail)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to encrypt sender contact")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase record"})
+		return
+	}
+	contactIndex := utils.DataKeys.SenderContactIndex(req.SenderContactType, req.SenderContactDetail)
+
 	purchase, err := h.Queries.CreatePurchase(ctx, repository.CreatePurchaseParams{
 		SenderName:          sql.NullString{String: req.SenderName, Valid: true},
 		SenderContactType:   sql.NullString{String: req.SenderContactType, Valid: true},
-		SenderContactDetail: sql.NullString{String: req.SenderContactDetail, Valid: true},
+		SenderContactDetail: sql.NullString{String: sealedContact, Valid: true},
+		SenderContactBidx:   sql.NullString{String: contactIndex, Valid: true},
 		Total:               sql.NullInt32{Int32: total, Valid: true},
 	})
 	if err != nil {
@@ -146,7 +214,21 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 	categoryCache := make(map[int32]string) // Cache for category names
 
 	for i, snapshot := range productSnapshots {
-		price, _ := strconv.ParseFloat(snapshot.Price.String, 64)
+		// A variant's own SKU, price and image replace the product's
+		variant := variantSnapshots[i]
+		sku, priceStr, fileID := snapshot.Sku, snapshot.Price, snapshot.FileID
+		var options map[string]string
+		if variant.ID != 0 {
+			sku = sql.NullString{String: variant.Sku, Valid: true}
+			priceStr = variantPrice(variant, snapshot.Price)
+			fileID = variantImage(variant, snapshot.FileID)
+			if err := json.Unmarshal(variant.Options, &options); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product variant"})
+				return
+			}
+		}
+
+		price, _ := strconv.ParseFloat(priceStr.String, 64)
 		itemTotal := price * float64(req.PurchasedItems[i].Qty)
 
 		err := h.Queries.CreatePurchaseItem(ctx, repository.CreatePurchaseItemParams{
@@ -154,6 +236,7 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 			ProductID:  snapshot.ProductID,
 			Qty:        sql.NullInt32{Int32: req.PurchasedItems[i].Qty, Valid: true},
 			Total:      sql.NullString{String: fmt.Sprintf("%.2f", itemTotal), Valid: true},
+			VariantID:  sql.NullInt32{Int32: variant.ID, Valid: variant.ID != 0},
 		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase item record"})
@@ -170,21 +253,23 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve category name"})
 				return
 			}
-			categoryName = utils.NullStringToString(catName)
+			categoryName = catName
 			categoryCache[snapshot.Category.Int32] = categoryName
 		}
 
 		// Build response snapshot
-		fileURI, thumbnailURI, _ := utils.GetFileInfo(h.Queries, ctx, snapshot.FileID)
-		priceInt, _ := strconv.Atoi(utils.NullStringToString(snapshot.Price))
+		fileURI, thumbnailURI, _ := utils.GetFileInfo(h.Queries, ctx, fileID)
+		priceInt, _ := strconv.Atoi(utils.NullStringToString(priceStr))
 		purchasedItemsResponse = append(purchasedItemsResponse, PurchasedItemResponse{
 			ProductID:        fmt.Sprintf("%d", snapshot.ProductID),
+			VariantID:        utils.NullInt32ToString(sql.NullInt32{Int32: variant.ID, Valid: variant.ID != 0}),
+			Options:          options,
 			Name:             utils.NullStringToString(snapshot.Name),
 			Category:         categoryName,
 			Qty:              req.PurchasedItems[i].Qty, // The quantity bought
 			Price:            priceInt,
-			SKU:              utils.NullStringToString(snapshot.Sku),
-			FileID:           utils.NullInt32ToString(snapshot.FileID),
+			SKU:              utils.NullStringToString(sku),
+			FileID:           utils.NullInt32ToString(fileID),
 			FileURI:          fileURI,
 			FileThumbnailURI: thumbnailURI,
 			CreatedAt:        snapshot.CreatedAt.Time.String(),
@@ -193,18 +278,51 @@ func (h *PurchaseHandler) CreatePurchase(c *gin.Context) {
 	}
 
 	var paymentDetailsResponse []PaymentDetailResponse
-	for sellerID, subtotal := range sellerSubtotals {
-		bankDetails, err := h.Queries.GetSellerBankDetailsByUserID(ctx, sellerID)
+	for storeID, subtotal := range storeSubtotals {
+		// Default account first
+		accounts, err := h.Queries.ListEnabledBankAccountsByStoreID(ctx, sql.NullInt32{Int32: storeID, Valid: true})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve seller bank details"})
 			return
 		}
-		paymentDetailsResponse = append(paymentDetailsResponse, PaymentDetailResponse{
-			BankAccountName:   utils.NullStringToString(bankDetails.BankAccountName),
-			BankAccountHolder: utils.NullStringToString(bankDetails.BankAccountHolder),
-			BankAccountNumber: utils.NullStringToString(bankDetails.BankAccountNumber),
-			TotalPrice:        subtotal,
-		})
+
+		paymentDetail := PaymentDetailResponse{
+			BankAccounts: make([]PaymentAccountResponse, 0, len(accounts)),
+			TotalPrice:   subtotal,
+		}
+		for i, account := range accounts {
+			account, err := openBankAccount(account)
+			if err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to decrypt seller bank account")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve seller bank details"})
+				return
+			}
+			accounts[i] = account
+
+			// Remember which accounts the buyer was told to pay to
+			err = h.Queries.CreatePurchasePaymentAccount(ctx, repository.CreatePurchasePaymentAccountParams{
+				PurchaseID:    purchase.ID,
+				BankAccountID: account.ID,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment accounts"})
+				return
+			}
+			paymentDetail.BankAccounts = append(paymentDetail.BankAccounts, PaymentAccountResponse{
+				BankAccountID:     fmt.Sprintf("%d", account.ID),
+				BankCode:          utils.NullStringToString(account.BankCode),
+				BankAccountName:   account.BankAccountName,
+				BankAccountHolder: account.BankAccountHolder,
+				BankAccountNumber: account.BankAccountNumber,
+				IsDefault:         account.IsDefault,
+			})
+		}
+		if len(accounts) > 0 {
+			paymentDetail.BankAccountName = accounts[0].BankAccountName
+			paymentDetail.BankAccountHolder = accounts[0].BankAccountHolder
+			paymentDetail.BankAccountNumber = accounts[0].BankAccountNumber
+		}
+		paymentDetailsResponse = append(paymentDetailsResponse, paymentDetail)
 	}
 
 	c.JSON(http.StatusCreated, CreatePurchaseResponse{
@@ -318,12 +436,19 @@ func (h *PurchaseHandler) ConfirmPayment(c *gin.Context) {
 			return
 		}
 
-		// Update product quantities (decrease even if it goes negative)
+		// Update product or variant quantities (decrease even if it goes negative)
 		for _, item := range items {
-			err = qtx.UpdateProductQuantity(ctx, repository.UpdateProductQuantityParams{
-				ProductID: item.ProductID,
-				Qty:       item.Qty,
-			})
+			if item.VariantID.Valid {
+				err = qtx.UpdateProductVariantQuantity(ctx, repository.UpdateProductVariantQuantityParams{
+					ID:  item.VariantID.Int32,
+					Qty: item.Qty.Int32,
+				})
+			} else {
+				err = qtx.UpdateProductQuantity(ctx, repository.UpdateProductQuantityParams{
+					ProductID: item.ProductID,
+					Qty:       item.Qty,
+				})
+			}
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product quantity"})
 				return
@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	Queries *repository.Queries
+}
+
+func NewRoleHandler(queries *repository.Queries) *RoleHandler {
+	return &RoleHandler{Queries: queries}
+}
+
+// Request struct
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=buyer seller admin"`
+}
+
+// PUT /v1/admin/users/:userId/role
+func (h *RoleHandler) SetUserRole(c *gin.Context) {
+	adminID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !utils.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	// An admin demoting themselves could leave nobody able to manage roles
+	if int32(userID) == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Can not change your own role"})
+		return
+	}
+
+	updated, err := h.Queries.SetUserRole(c, repository.SetUserRoleParams{
+		ID:   int32(userID),
+		Role: req.Role,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+
+	// Access tokens carry the old role, revoking them makes clients refresh and pick up
+	// the new one while their sessions stay signed in
+	if err := h.Queries.RevokeUserAccessTokens(c, int32(userID)); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to revoke access tokens after role change")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"userId": strconv.FormatInt(userID, 10),
+		"role":   req.Role,
+	})
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SellerHandler struct {
+	Queries *repository.Queries
+}
+
+func NewSellerHandler(queries *repository.Queries) *SellerHandler {
+	return &SellerHandler{Queries: queries}
+}
+
+// Response struct, the storefront is public and carries no contact or bank details
+type SellerResponse struct {
+	SellerID         string    `json:"sellerId"`
+	StoreName        string    `json:"storeName"`
+	FileID           string    `json:"fileId"`
+	FileURI          string    `json:"fileUri"`
+	FileThumbnailURI string    `json:"fileThumbnailUri"`
+	ProductCount     int64     `json:"productCount"`
+	SalesCount       int64     `json:"salesCount"`
+	JoinedAt         time.Time `json:"joinedAt"`
+}
+
+// getSeller looks up the storefront of the :sellerId path parameter,
+// or writes the response and returns false
+func getSeller(c *gin.Context, queries *repository.Queries) (repository.GetSellerStorefrontRow, bool) {
+	sellerID, err := strconv.Atoi(c.Param("sellerId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sellerId is not found"})
+		return repository.GetSellerStorefrontRow{}, false
+	}
+
+	seller, err := queries.GetSellerStorefront(c, int32(sellerID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "sellerId is not found"})
+			return repository.GetSellerStorefrontRow{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return repository.GetSellerStorefrontRow{}, false
+	}
+	return seller, true
+}
+
+// GET /v1/seller/:sellerId
+func (h *SellerHandler) GetSeller(c *gin.Context) {
+	seller, ok := getSeller(c, h.Queries)
+	if !ok {
+		return
+	}
+
+	fileURI, fileThumbnailURI, err := utils.GetFileInfo(h.Queries, c, seller.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error fetching file info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SellerResponse{
+		SellerID:         strconv.FormatInt(int64(seller.ID), 10),
+		StoreName:        utils.NullStringToString(seller.StoreName),
+		FileID:           utils.NullInt32ToString(seller.FileID),
+		FileURI:          fileURI,
+		FileThumbnailURI: fileThumbnailURI,
+		ProductCount:     seller.ProductCount,
+		SalesCount:       seller.SalesCount,
+		JoinedAt:         seller.CreatedAt.Time,
+	})
+}
+
+// GET /v1/seller/:sellerId/products
+func (h *SellerHandler) GetSellerProducts(c *gin.Context) {
+	seller, ok := getSeller(c, h.Queries)
+	if !ok {
+		return
+	}
+
+	listProducts(c, h.Queries, sql.NullInt32{Int32: seller.ID, Valid: true})
+}
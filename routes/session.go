@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	Queries *repository.Queries
+}
+
+func NewSessionHandler(queries *repository.Queries) *SessionHandler {
+	return &SessionHandler{Queries: queries}
+}
+
+// Response struct
+type SessionResponse struct {
+	SessionID  string    `json:"sessionId"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+// GET /v1/user/sessions
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.Queries.ListActiveSessionsByUserID(c, userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	currentHash := utils.HashToken(c.GetString("session_id"))
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, SessionResponse{
+			SessionID:  strconv.FormatInt(int64(s.ID), 10),
+			Device:     utils.NullStringToString(s.UserAgent),
+			IPAddress:  utils.NullStringToString(s.IpAddress),
+			CreatedAt:  s.CreatedAt.Time,
+			LastSeenAt: s.LastSeenAt.Time,
+			Current:    s.TokenHash == currentHash,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /v1/user/sessions/:id
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session is not found"})
+		return
+	}
+
+	// Access and refresh tokens of the session stop working once it is revoked
+	revoked, err := h.Queries.RevokeSessionByID(c, repository.RevokeSessionByIDParams{
+		ID:     int32(sessionID),
+		UserID: userID,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to revoke session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,672 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutuplapak-go/provider"
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const storeInvitationTTL = 7 * 24 * time.Hour
+
+type StoreHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+	Mailer  provider.Mailer
+	SMS     provider.SMSSender
+}
+
+func NewStoreHandler(queries *repository.Queries, db *sql.DB, mailer provider.Mailer, sms provider.SMSSender) *StoreHandler {
+	return &StoreHandler{Queries: queries, DB: db, Mailer: mailer, SMS: sms}
+}
+
+// Request structs
+
+// InviteStaffRequest invites either an email or a phone
+type InviteStaffRequest struct {
+	Email       string   `json:"email" binding:"omitempty,email"`
+	Phone       string   `json:"phone"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type StaffPermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// Response structs
+type StoreResponse struct {
+	StoreID     string   `json:"storeId"`
+	OwnerID     string   `json:"ownerId"`
+	StoreName   string   `json:"storeName"`
+	IsOwner     bool     `json:"isOwner"`
+	Permissions []string `json:"permissions"`
+}
+
+type StaffMemberResponse struct {
+	UserID      string    `json:"userId"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	Permissions []string  `json:"permissions"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+type StoreInvitationResponse struct {
+	InvitationID string    `json:"invitationId"`
+	Email        string    `json:"email"`
+	Phone        string    `json:"phone"`
+	Permissions  []string  `json:"permissions"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type StaffResponse struct {
+	Members     []StaffMemberResponse     `json:"members"`
+	Invitations []StoreInvitationResponse `json:"invitations"`
+}
+
+type StoreOrderItemResponse struct {
+	ProductID string `json:"productId"`
+	VariantID string `json:"variantId"`
+	Name      string `json:"name"`
+	// The variant's SKU when a variant was bought
+	Sku   string `json:"sku"`
+	Qty   int32  `json:"qty"`
+	Total int    `json:"total"`
+}
+
+// StoreOrderResponse is a purchase as the store sees it, only its own items and share of the total
+type StoreOrderResponse struct {
+	PurchaseID          string                   `json:"purchaseId"`
+	SenderName          string                   `json:"senderName"`
+	SenderContactType   string                   `json:"senderContactType"`
+	SenderContactDetail string                   `json:"senderContactDetail"`
+	Items               []StoreOrderItemResponse `json:"items"`
+	Subtotal            int64                    `json:"subtotal"`
+	IsPaid              bool                     `json:"isPaid"`
+	PaymentFileID       string                   `json:"paymentFileId"`
+	PaymentConfirmedAt  *time.Time               `json:"paymentConfirmedAt"`
+	CreatedAt           time.Time                `json:"createdAt"`
+}
+
+func toStoreInvitationResponse(invitation repository.StoreInvitation) StoreInvitationResponse {
+	return StoreInvitationResponse{
+		InvitationID: strconv.FormatInt(int64(invitation.ID), 10),
+		Email:        utils.NullStringToString(invitation.Email),
+		Phone:        utils.NullStringToString(invitation.Phone),
+		Permissions:  invitation.Permissions,
+		ExpiresAt:    invitation.ExpiresAt,
+		CreatedAt:    invitation.CreatedAt,
+	}
+}
+
+// storePermissions returns what the user may do in the store. The owner holds every
+// permission, member is false when the user does not work in the store at all.
+func storePermissions(c *gin.Context, queries *repository.Queries, store repository.Store, userID int32) ([]string, bool, error) {
+	if store.OwnerID == userID {
+		return utils.StorePermissions, true, nil
+	}
+	member, err := queries.GetStoreMember(c, repository.GetStoreMemberParams{
+		StoreID: store.ID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return member.Permissions, true, nil
+}
+
+// authorizeStore loads the store of the storeId parameter and checks the user holds the
+// permission, an empty permission only lets the owner through. Stores the user does not
+// work in are reported as not found. It writes the response and returns false on failure.
+func authorizeStore(c *gin.Context, queries *repository.Queries, userID int32, permission string) (repository.Store, bool) {
+	storeID, err := strconv.Atoi(c.Param("storeId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "storeId is not found"})
+		return repository.Store{}, false
+	}
+	store, err := queries.GetStoreByID(c, int32(storeID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "storeId is not found"})
+			return repository.Store{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return repository.Store{}, false
+	}
+	permissions, member, err := storePermissions(c, queries, store, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return repository.Store{}, false
+	}
+	if !member {
+		c.JSON(http.StatusNotFound, gin.H{"error": "storeId is not found"})
+		return repository.Store{}, false
+	}
+	if permission == "" && store.OwnerID != userID || permission != "" && !slices.Contains(permissions, permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return repository.Store{}, false
+	}
+	return store, true
+}
+
+// validStaffPermissions sorts and deduplicates the permissions, false when one is unknown
+func validStaffPermissions(permissions []string) ([]string, bool) {
+	for _, permission := range permissions {
+		if !utils.ValidStorePermission(permission) {
+			return nil, false
+		}
+	}
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+	return slices.Compact(permissions), true
+}
+
+// GET /v1/user/stores
+func (h *StoreHandler) ListStores(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	stores, err := h.Queries.ListUserStores(c, userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list stores")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]StoreResponse, 0, len(stores))
+	for _, store := range stores {
+		isOwner := store.OwnerID == userID
+		permissions := store.Permissions
+		if isOwner {
+			permissions = utils.StorePermissions
+		}
+		response = append(response, StoreResponse{
+			StoreID:     strconv.FormatInt(int64(store.ID), 10),
+			OwnerID:     strconv.FormatInt(int64(store.OwnerID), 10),
+			StoreName:   utils.NullStringToString(store.StoreName),
+			IsOwner:     isOwner,
+			Permissions: permissions,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /v1/store/:storeId/staff
+func (h *StoreHandler) ListStaff(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, "")
+	if !ok {
+		return
+	}
+
+	members, err := h.Queries.ListStoreMembers(c, store.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	invitations, err := h.Queries.ListStoreInvitations(c, store.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := StaffResponse{
+		Members:     make([]StaffMemberResponse, 0, len(members)),
+		Invitations: make([]StoreInvitationResponse, 0, len(invitations)),
+	}
+	for _, member := range members {
+		response.Members = append(response.Members, StaffMemberResponse{
+			UserID:      strconv.FormatInt(int64(member.UserID), 10),
+			Email:       utils.NullStringToString(member.Email),
+			Phone:       utils.NullStringToString(member.Phone),
+			Permissions: member.Permissions,
+			JoinedAt:    member.CreatedAt,
+		})
+	}
+	for _, invitation := range invitations {
+		response.Invitations = append(response.Invitations, toStoreInvitationResponse(invitation))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /v1/store/:storeId/invitations
+func (h *StoreHandler) InviteStaff(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, "")
+	if !ok {
+		return
+	}
+
+	var req InviteStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	if (req.Email == "") == (req.Phone == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	permissions, ok := validStaffPermissions(req.Permissions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission"})
+		return
+	}
+
+	var email, phone sql.NullString
+	if req.Email != "" {
+		email = sql.NullString{String: strings.ToLower(req.Email), Valid: true}
+	} else {
+		// Phones are stored and matched in E.164
+		normalized, ok := utils.NormalizePhone(req.Phone)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+			return
+		}
+		phone = sql.NullString{String: normalized, Valid: true}
+	}
+
+	token := utils.GenerateToken()
+	invitation, err := h.Queries.CreateStoreInvitation(c, repository.CreateStoreInvitationParams{
+		StoreID:     store.ID,
+		Email:       email,
+		Phone:       phone,
+		Permissions: permissions,
+		TokenHash:   utils.HashToken(token),
+		InvitedBy:   sql.NullInt32{Int32: userID, Valid: true},
+		ExpiresAt:   time.Now().Add(storeInvitationTTL),
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to create store invitation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	link := utils.LinkURL("/store-invitation", token)
+	if email.Valid {
+		body := fmt.Sprintf("You have been invited to help run a store on TutupLapak. Sign in or create an account with this email and open the link below to join.\n\n%s\n\nThe link expires in 7 days. If you did not expect this, ignore this email.", link)
+		err = h.Mailer.SendMail(c, email.String, "You have been invited to a store", body)
+	} else {
+		err = h.SMS.SendSMS(c, phone.String, "You have been invited to help run a store on TutupLapak. Sign in with this phone and open "+link)
+	}
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to send store invitation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toStoreInvitationResponse(invitation))
+}
+
+// DELETE /v1/store/:storeId/invitations/:invitationId
+func (h *StoreHandler) RevokeInvitation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, "")
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.Atoi(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitationId is not found"})
+		return
+	}
+	deleted, err := h.Queries.DeleteStoreInvitation(c, repository.DeleteStoreInvitationParams{
+		ID:      int32(invitationID),
+		StoreID: store.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitationId is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// POST /v1/store/invitations/accept
+func (h *StoreHandler) AcceptInvitation(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	invitation, err := h.Queries.GetStoreInvitationByHash(c, utils.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// The invitation is for whoever owns the email or phone it was sent to, only a verified
+	// contact proves that
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	emailMatches := invitation.Email.Valid && user.Email.Valid && strings.EqualFold(invitation.Email.String, user.Email.String)
+	phoneMatches := invitation.Phone.Valid && user.Phone.Valid && invitation.Phone.String == user.Phone.String
+	if !emailMatches && !phoneMatches {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The invitation was sent to another account"})
+		return
+	}
+	if !(emailMatches && user.EmailVerifiedAt.Valid) && !(phoneMatches && user.PhoneVerifiedAt.Valid) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify the email or phone the invitation was sent to first"})
+		return
+	}
+
+	store, err := h.Queries.GetStoreByID(c, invitation.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if store.OwnerID == userID {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already own this store"})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	accepted, err := qtx.AcceptStoreInvitation(c, invitation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if accepted == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	err = qtx.AddStoreMember(c, repository.AddStoreMemberParams{
+		StoreID:     store.ID,
+		UserID:      userID,
+		Permissions: invitation.Permissions,
+		InvitedBy:   invitation.InvitedBy,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to add store member")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	owner, err := h.Queries.GetUserByID(c, store.OwnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusOK, StoreResponse{
+		StoreID:     strconv.FormatInt(int64(store.ID), 10),
+		OwnerID:     strconv.FormatInt(int64(store.OwnerID), 10),
+		StoreName:   utils.NullStringToString(owner.StoreName),
+		IsOwner:     false,
+		Permissions: invitation.Permissions,
+	})
+}
+
+// PUT /v1/store/:storeId/staff/:userId
+func (h *StoreHandler) UpdateStaff(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, "")
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+	var req StaffPermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+	permissions, ok := validStaffPermissions(req.Permissions)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission"})
+		return
+	}
+
+	updated, err := h.Queries.UpdateStoreMemberPermissions(c, repository.UpdateStoreMemberPermissionsParams{
+		StoreID:     store.ID,
+		UserID:      int32(memberID),
+		Permissions: permissions,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"userId": c.Param("userId"), "permissions": permissions})
+}
+
+// DELETE /v1/store/:storeId/staff/:userId
+// The owner removes staff, staff can remove themselves to leave the store.
+func (h *StoreHandler) RemoveStaff(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	memberID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+
+	var storeID int32
+	if int32(memberID) == userID {
+		// Leaving only touches the caller's own membership, which must exist
+		id, err := strconv.Atoi(c.Param("storeId"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "storeId is not found"})
+			return
+		}
+		storeID = int32(id)
+	} else {
+		store, ok := authorizeStore(c, h.Queries, userID, "")
+		if !ok {
+			return
+		}
+		storeID = store.ID
+	}
+
+	removed, err := h.Queries.RemoveStoreMember(c, repository.RemoveStoreMemberParams{
+		StoreID: storeID,
+		UserID:  int32(memberID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "userId is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GET /v1/store/:storeId/orders
+func (h *StoreHandler) ListOrders(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, utils.StorePermissionViewOrders)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 0 {
+		limit = 5
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	storeID := sql.NullInt32{Int32: store.ID, Valid: true}
+	orders, err := h.Queries.ListStoreOrders(c, repository.ListStoreOrdersParams{
+		StoreID: storeID,
+		Limit:   int32(limit),
+		Offset:  int32(offset),
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to list store orders")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	response := make([]StoreOrderResponse, 0, len(orders))
+	for _, order := range orders {
+		items, err := h.Queries.ListStoreOrderItems(c, repository.ListStoreOrderItemsParams{
+			PurchaseID: order.ID,
+			StoreID:    storeID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		// Staff need the buyer's contact to follow up on the order
+		detail, err := utils.DataKeys.Decrypt(utils.FieldSenderContactDetail, order.SenderContactDetail.String)
+		if err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to decrypt sender contact")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+
+		orderResponse := StoreOrderResponse{
+			PurchaseID:          strconv.FormatInt(int64(order.ID), 10),
+			SenderName:          utils.NullStringToString(order.SenderName),
+			SenderContactType:   utils.NullStringToString(order.SenderContactType),
+			SenderContactDetail: detail,
+			Items:               make([]StoreOrderItemResponse, 0, len(items)),
+			Subtotal:            order.Subtotal,
+			IsPaid:              order.IsPaid.Bool,
+			PaymentFileID:       utils.NullInt32ToString(order.PaymentFileID),
+			PaymentConfirmedAt:  nullTimeToPointer(order.ConfirmedAt),
+			CreatedAt:           order.CreatedAt.Time,
+		}
+		for _, item := range items {
+			total, _ := strconv.Atoi(utils.NullStringToString(item.Total))
+			sku := item.Sku
+			if item.VariantSku.Valid {
+				sku = item.VariantSku
+			}
+			orderResponse.Items = append(orderResponse.Items, StoreOrderItemResponse{
+				ProductID: strconv.FormatInt(int64(item.ProductID), 10),
+				VariantID: utils.NullInt32ToString(item.VariantID),
+				Name:      utils.NullStringToString(item.Name),
+				Sku:       utils.NullStringToString(sku),
+				Qty:       item.Qty.Int32,
+				Total:     total,
+			})
+		}
+		response = append(response, orderResponse)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /v1/store/:storeId/orders/:purchaseId/confirm
+func (h *StoreHandler) ConfirmOrderPayment(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	store, ok := authorizeStore(c, h.Queries, userID, utils.StorePermissionConfirmPayment)
+	if !ok {
+		return
+	}
+
+	purchaseID, err := strconv.Atoi(c.Param("purchaseId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "purchaseId is not found"})
+		return
+	}
+	confirmed, err := h.Queries.ConfirmStorePayment(c, repository.ConfirmStorePaymentParams{
+		ConfirmedBy: sql.NullInt32{Int32: userID, Valid: true},
+		PurchaseID:  int32(purchaseID),
+		StoreID:     store.ID,
+	})
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to confirm payment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// Without a payment detail the buyer has not sent the store's payment proof yet
+	if confirmed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "purchaseId is not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment confirmed"})
+}
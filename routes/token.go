@@ -0,0 +1,293 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+}
+
+func NewTokenHandler(queries *repository.Queries, db *sql.DB) *TokenHandler {
+	return &TokenHandler{Queries: queries, DB: db}
+}
+
+// Request struct
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// issueTokens creates an access token and a refresh token for the given family.
+// A new login passes a fresh family ID, a refresh passes the family of the rotated token.
+func issueTokens(c *gin.Context, queries *repository.Queries, userID int32, familyID string) (string, string, error) {
+	// The role is read on every issue so a role change applies with the next refresh
+	role, err := queries.GetUserRole(c, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	jti := utils.GenerateToken()
+	accessToken, err := utils.GenerateJWTToken(uint(userID), role, jti, familyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	refreshToken := utils.GenerateToken()
+	_, err = queries.CreateRefreshToken(c, repository.CreateRefreshTokenParams{
+		UserID:          userID,
+		TokenHash:       utils.HashToken(refreshToken),
+		FamilyID:        familyID,
+		AccessJti:       jti,
+		AccessExpiresAt: now.Add(utils.AccessTokenTTL),
+		ExpiresAt:       now.Add(utils.RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// startSession records a new device session and issues its first token pair.
+// The refresh token family doubles as the session token.
+func startSession(c *gin.Context, queries *repository.Queries, userID int32) (string, string, error) {
+	familyID := utils.GenerateToken()
+	err := utils.GlobalTokenStore.StoreToken(c, familyID, userID, utils.SessionInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return issueTokens(c, queries, userID, familyID)
+}
+
+// revokeTokenFamily ends a session and invalidates every refresh and access token that belongs to it
+func revokeTokenFamily(c *gin.Context, queries *repository.Queries, familyID string) error {
+	if err := utils.GlobalTokenStore.DeleteToken(c, familyID); err != nil {
+		return err
+	}
+	if err := queries.RevokeFamilyAccessTokens(c, familyID); err != nil {
+		return err
+	}
+	return queries.RevokeRefreshTokenFamily(c, familyID)
+}
+
+// revokeCurrentAccessToken blacklists the access token used for the current request
+func revokeCurrentAccessToken(c *gin.Context, queries *repository.Queries, userID int32) error {
+	jti := c.GetString("token_jti")
+	expiresAt, ok := c.Get("token_expires_at")
+	if jti == "" || !ok {
+		return nil
+	}
+	return queries.RevokeAccessToken(c, repository.RevokeAccessTokenParams{
+		Jti:       jti,
+		UserID:    sql.NullInt32{Int32: userID, Valid: true},
+		ExpiresAt: expiresAt.(time.Time),
+	})
+}
+
+// POST /v1/token/refresh
+func (h *TokenHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	stored, err := h.Queries.GetRefreshTokenByHash(c, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.Logger.Error().Err(err).Msg("Failed to get refresh token")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// A rotated token being presented again means it leaked, kill the whole family
+	if stored.RevokedAt.Valid {
+		utils.Logger.Warn().Int32("user_id", stored.UserID).Str("family_id", stored.FamilyID).Msg("Refresh token reuse detected")
+		if err := revokeTokenFamily(c, h.Queries, stored.FamilyID); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to revoke token family")
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// The session may have been revoked from another device
+	if _, exists := utils.GlobalTokenStore.GetUserID(c, stored.FamilyID); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := h.Queries.WithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(c, stored.ID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to rotate refresh token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if rotated == 0 {
+		// Another request rotated this token first, treat it as reuse
+		utils.Logger.Warn().Int32("user_id", stored.UserID).Str("family_id", stored.FamilyID).Msg("Concurrent refresh token reuse detected")
+		tx.Rollback()
+		if err := revokeTokenFamily(c, h.Queries, stored.FamilyID); err != nil {
+			utils.Logger.Error().Err(err).Msg("Failed to revoke token family")
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := qtx.GetUserByID(c, stored.UserID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to get user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	accessToken, refreshToken, err := issueTokens(c, qtx, user.ID, stored.FamilyID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("failed to create token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	if err := utils.GlobalTokenStore.ExtendToken(c, stored.FamilyID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to extend session")
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Email:        utils.NullStringToString(user.Email),
+		Phone:        utils.NullStringToString(user.Phone),
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// POST /v1/logout
+func (h *TokenHandler) Logout(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// End the current session together with its refresh token family
+	err = revokeTokenFamily(c, h.Queries, c.GetString("session_id"))
+	if err == nil {
+		err = revokeCurrentAccessToken(c, h.Queries, userID)
+	}
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to logout")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// POST /v1/logout/all
+func (h *TokenHandler) LogoutAll(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := revokeAllUserTokens(c, h.Queries, userID); err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to logout from all devices")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+// revokeAllUserTokens signs the user out everywhere, including the current request's token
+func revokeAllUserTokens(c *gin.Context, queries *repository.Queries, userID int32) error {
+	if err := utils.GlobalTokenStore.DeleteUserTokens(c, userID); err != nil {
+		return err
+	}
+	if err := queries.RevokeUserAccessTokens(c, userID); err != nil {
+		return err
+	}
+	if err := queries.RevokeUserRefreshTokens(c, userID); err != nil {
+		return err
+	}
+	return revokeCurrentAccessToken(c, queries, userID)
+}
+
+// revokeOtherUserTokens signs a user out everywhere except the session of the current request
+func revokeOtherUserTokens(c *gin.Context, queries *repository.Queries, userID int32) error {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		return revokeAllUserTokens(c, queries, userID)
+	}
+	if err := utils.GlobalTokenStore.DeleteOtherUserTokens(c, userID, sessionID); err != nil {
+		return err
+	}
+	err := queries.RevokeOtherUserAccessTokens(c, repository.RevokeOtherUserAccessTokensParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		return err
+	}
+	return queries.RevokeOtherUserRefreshTokens(c, repository.RevokeOtherUserRefreshTokensParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+}
+
+// Cleanup expired refresh tokens and revocations periodically
+func (h *TokenHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			ctx := context.Background()
+			if err := h.Queries.DeleteExpiredRefreshTokens(ctx); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired refresh tokens")
+			}
+			if err := h.Queries.DeleteExpiredRevokedTokens(ctx); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired revoked tokens")
+			}
+		}
+	}()
+}
+
+// GET /.well-known/jwks.json
+func (h *TokenHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.JWTKeys.JWKS()})
+}
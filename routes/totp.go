@@ -0,0 +1,435 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a user has to enter the TOTP code after the password was accepted
+const twoFactorChallengeTTL = 5 * time.Minute
+
+type TOTPHandler struct {
+	Queries *repository.Queries
+	DB      *sql.DB
+}
+
+func NewTOTPHandler(queries *repository.Queries, db *sql.DB) *TOTPHandler {
+	return &TOTPHandler{Queries: queries, DB: db}
+}
+
+// Request structs
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// SecondFactorRequest takes either a code from the authenticator or a recovery code
+type SecondFactorRequest struct {
+	Code         string `json:"code" binding:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type Login2FARequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	SecondFactorRequest
+}
+
+// Response structs
+type EnrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+	ExpiresIn         int    `json:"expiresIn"`
+}
+
+var errTOTPInvalid = errors.New("invalid two-factor code")
+
+// verifyTOTP checks a code against the secret and burns its time step
+func verifyTOTP(c *gin.Context, queries *repository.Queries, userID int32, secret string, code string) error {
+	step, ok := utils.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return errTOTPInvalid
+	}
+	used, err := queries.UseTOTPStep(c, repository.UseTOTPStepParams{
+		ID:           userID,
+		TotpLastStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		// The code was already used, replays are refused
+		return errTOTPInvalid
+	}
+	return nil
+}
+
+// verifySecondFactor accepts a TOTP code or, when the authenticator is lost, a recovery code
+func verifySecondFactor(c *gin.Context, queries *repository.Queries, userID int32, totp repository.GetUserTOTPRow, req SecondFactorRequest) error {
+	if !totp.TotpEnabledAt.Valid {
+		return errTOTPInvalid
+	}
+	if req.Code != "" {
+		return verifyTOTP(c, queries, userID, totp.TotpSecret.String, req.Code)
+	}
+	if req.RecoveryCode == "" {
+		return errTOTPInvalid
+	}
+	used, err := queries.UseRecoveryCode(c, repository.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: utils.HashRecoveryCode(req.RecoveryCode),
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errTOTPInvalid
+	}
+	return nil
+}
+
+// checkSecondFactor runs verify under the user's 2FA lockout, codes are short and every
+// guess counts no matter which endpoint it is sent to. A wrong code is answered with
+// status. It writes the response and returns false when the check failed.
+func checkSecondFactor(c *gin.Context, queries *repository.Queries, userID int32, status int, verify func() error) bool {
+	throttle := newLoginThrottle(c, "2fa:"+strconv.FormatInt(int64(userID), 10))
+	retryAfter, err := throttle.reserve(c, queries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return false
+	}
+	if retryAfter > 0 {
+		writeLoginLocked(c, retryAfter)
+		return false
+	}
+
+	if err := verify(); err != nil {
+		if errors.Is(err, errTOTPInvalid) {
+			c.JSON(status, gin.H{"error": "Invalid two-factor code"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return false
+	}
+	throttle.recordSuccess(c, queries)
+	return true
+}
+
+// requireFreshTOTP enforces a current TOTP code for sensitive changes when the user
+// has 2FA enabled. It writes the response and returns false when the change must stop.
+func requireFreshTOTP(c *gin.Context, queries *repository.Queries, userID int32, code string) bool {
+	totp, err := queries.GetUserTOTP(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return false
+	}
+	if !totp.TotpEnabledAt.Valid {
+		return true
+	}
+	if code == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor code required"})
+		return false
+	}
+	return checkSecondFactor(c, queries, userID, http.StatusForbidden, func() error {
+		return verifyTOTP(c, queries, userID, totp.TotpSecret.String, code)
+	})
+}
+
+// finishLogin completes a login whose first factor was accepted. Users with 2FA get a
+// challenge token for POST /v1/login/2fa instead of a session.
+func finishLogin(c *gin.Context, queries *repository.Queries, userID int32, email, phone string) {
+	totp, err := queries.GetUserTOTP(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if totp.TotpEnabledAt.Valid {
+		// The jti makes the challenge single use, see Login2FA
+		challenge, err := utils.SignLinkToken(utils.LinkClaims{
+			ID:      utils.GenerateToken(),
+			UserID:  userID,
+			Purpose: utils.LinkPurposeLogin2FA,
+		}, twoFactorChallengeTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(twoFactorChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	// Start a new session with access and refresh tokens
+	token, refreshToken, err := startSession(c, queries, userID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("failed to create token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{
+		Email:        email,
+		Phone:        phone,
+		Token:        token,
+		RefreshToken: refreshToken,
+	})
+}
+
+// POST /v1/login/2fa
+func (h *TOTPHandler) Login2FA(c *gin.Context) {
+	var req Login2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	claims, err := utils.VerifyLinkToken(req.ChallengeToken, utils.LinkPurposeLogin2FA)
+	if err == nil && claims.ID == "" {
+		err = utils.ErrInvalidLinkToken
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	totp, err := h.Queries.GetUserTOTP(c, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+	ok := checkSecondFactor(c, h.Queries, claims.UserID, http.StatusUnauthorized, func() error {
+		return verifySecondFactor(c, h.Queries, claims.UserID, totp, req.SecondFactorRequest)
+	})
+	if !ok {
+		return
+	}
+
+	// A challenge is good for one session, a replayed one is refused even with a valid code
+	used, err := h.Queries.UseLoginChallenge(c, repository.UseLoginChallengeParams{
+		Jti:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if used == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	// Start a new session with access and refresh tokens
+	token, refreshToken, err := startSession(c, h.Queries, user.ID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("failed to create token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{
+		Email:        utils.NullStringToString(user.Email),
+		Phone:        utils.NullStringToString(user.Phone),
+		Token:        token,
+		RefreshToken: refreshToken,
+	})
+}
+
+// POST /v1/user/2fa/totp
+func (h *TOTPHandler) EnrollTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.Queries.GetUserByID(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	err = h.Queries.SetTOTPSecret(c, repository.SetTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	account := utils.NullStringToString(user.Email)
+	if account == "" {
+		account = utils.NullStringToString(user.Phone)
+	}
+	c.JSON(http.StatusOK, EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthURI: utils.TOTPURI("TutupLapak", account, secret),
+	})
+}
+
+// POST /v1/user/2fa/totp/verify
+func (h *TOTPHandler) ConfirmTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	totp, err := h.Queries.GetUserTOTP(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if totp.TotpEnabledAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !totp.TotpSecret.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start the enrollment first"})
+		return
+	}
+
+	// A valid code proves the authenticator holds the secret
+	if err := verifyTOTP(c, h.Queries, userID, totp.TotpSecret.String, req.Code); err != nil {
+		if errors.Is(err, errTOTPInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	if err := qtx.EnableTOTP(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	// Recovery codes are only ever shown here
+	recoveryCodes := utils.GenerateRecoveryCodes(utils.RecoveryCodeCount)
+	for _, code := range recoveryCodes {
+		err := qtx.CreateRecoveryCode(c, repository.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: utils.HashRecoveryCode(code),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+// DELETE /v1/user/2fa/totp
+func (h *TOTPHandler) DisableTOTP(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req SecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return
+	}
+
+	totp, err := h.Queries.GetUserTOTP(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if !totp.TotpEnabledAt.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	// A stolen access token alone must not be enough to turn 2FA off
+	ok := checkSecondFactor(c, h.Queries, userID, http.StatusForbidden, func() error {
+		return verifySecondFactor(c, h.Queries, userID, totp, req)
+	})
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	defer tx.Rollback()
+	qtx := h.Queries.WithTx(tx)
+
+	if err := qtx.DisableTOTP(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Cleanup expired login challenges periodically
+func (h *TOTPHandler) StartCleanupRoutine() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			if err := h.Queries.DeleteExpiredLoginChallenges(context.Background()); err != nil {
+				utils.Logger.Error().Err(err).Msg("Failed to delete expired login challenges")
+			}
+		}
+	}()
+}
@@ -0,0 +1,309 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tutuplapak-go/repository"
+	"tutuplapak-go/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VariantHandler struct {
+	Queries *repository.Queries
+}
+
+func NewVariantHandler(queries *repository.Queries) *VariantHandler {
+	return &VariantHandler{Queries: queries}
+}
+
+// Request struct
+type VariantRequest struct {
+	// Unique per seller like product SKUs
+	Sku string `json:"sku" binding:"required,max=32"`
+	// What sets the variant apart, e.g. {"size": "M", "color": "black"}
+	Options map[string]string `json:"options" binding:"required,min=1,max=5,dive,keys,min=1,max=32,endkeys,min=1,max=64"`
+	Qty     int32             `json:"qty" binding:"min=0"`
+	// The product's price is used when omitted
+	Price *int32 `json:"price" binding:"omitempty,min=100"`
+	// The product's image is used when omitted
+	FileID string `json:"fileId"`
+}
+
+// Response struct
+type VariantResponse struct {
+	VariantID        string            `json:"variantId"`
+	Sku              string            `json:"sku"`
+	Options          map[string]string `json:"options"`
+	Qty              int32             `json:"qty"`
+	Price            int32             `json:"price"`
+	FileID           string            `json:"fileId"`
+	FileURI          string            `json:"fileUri"`
+	FileThumbnailURI string            `json:"fileThumbnailUri"`
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+}
+
+// variantPrice is what a variant sells for, its override or the product's price
+func variantPrice(variant repository.ProductVariant, productPrice sql.NullString) sql.NullString {
+	if variant.Price.Valid {
+		return variant.Price
+	}
+	return productPrice
+}
+
+// variantImage is the variant's image, or the product's when it has none
+func variantImage(variant repository.ProductVariant, productFileID sql.NullInt32) sql.NullInt32 {
+	if variant.FileID.Valid {
+		return variant.FileID
+	}
+	return productFileID
+}
+
+// toVariantResponses builds the responses of a product's variants, priced and pictured
+// with the product's values where they have no override
+func toVariantResponses(c *gin.Context, queries *repository.Queries, variants []repository.ProductVariant, productPrice sql.NullString, productFileID sql.NullInt32) ([]VariantResponse, error) {
+	response := make([]VariantResponse, 0, len(variants))
+	for _, variant := range variants {
+		var options map[string]string
+		if err := json.Unmarshal(variant.Options, &options); err != nil {
+			return nil, err
+		}
+		fileID := variantImage(variant, productFileID)
+		fileURI, fileThumbnailURI, err := utils.GetFileInfo(queries, c, fileID)
+		if err != nil {
+			return nil, err
+		}
+		price, _ := strconv.Atoi(utils.NullStringToString(variantPrice(variant, productPrice)))
+		response = append(response, VariantResponse{
+			VariantID:        strconv.FormatInt(int64(variant.ID), 10),
+			Sku:              variant.Sku,
+			Options:          options,
+			Qty:              variant.Qty,
+			Price:            int32(price),
+			FileID:           utils.NullInt32ToString(fileID),
+			FileURI:          fileURI,
+			FileThumbnailURI: fileThumbnailURI,
+			CreatedAt:        variant.CreatedAt,
+			UpdatedAt:        variant.UpdatedAt,
+		})
+	}
+	return response, nil
+}
+
+// bindVariant validates the request of a create or update.
+// It writes the response and returns false on failure.
+func (h *VariantHandler) bindVariant(c *gin.Context) (repository.UpdateProductVariantParams, bool) {
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return repository.UpdateProductVariantParams{}, false
+	}
+
+	// SKU and option values must not be whitespace-only
+	if strings.TrimSpace(req.Sku) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return repository.UpdateProductVariantParams{}, false
+	}
+	for key, value := range req.Options {
+		if strings.TrimSpace(key) == "" || strings.TrimSpace(value) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+			return repository.UpdateProductVariantParams{}, false
+		}
+	}
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error"})
+		return repository.UpdateProductVariantParams{}, false
+	}
+
+	params := repository.UpdateProductVariantParams{
+		Sku:     req.Sku,
+		Options: options,
+		Qty:     req.Qty,
+	}
+	if req.Price != nil {
+		params.Price = sql.NullString{String: fmt.Sprintf("%d", *req.Price), Valid: true}
+	}
+	if req.FileID != "" {
+		fileID, err := strconv.Atoi(req.FileID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+			return repository.UpdateProductVariantParams{}, false
+		}
+		if _, err := h.Queries.GetFileByID(c, int32(fileID)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileId is not valid"})
+			return repository.UpdateProductVariantParams{}, false
+		}
+		params.FileID = sql.NullInt32{Int32: int32(fileID), Valid: true}
+	}
+
+	return params, true
+}
+
+// writeVariantError answers a failed insert or update of a variant, a taken SKU is
+// reported by the unique_variant_sku_per_user index
+func writeVariantError(c *gin.Context, err error) {
+	if strings.Contains(err.Error(), "unique_variant_sku_per_user") {
+		c.JSON(http.StatusConflict, gin.H{"error": "sku already exists (per account basis)"})
+		return
+	}
+	utils.Logger.Error().Err(err).Msg("Failed to save product variant")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+}
+
+// GET /v1/product/:productId/variants
+func (h *VariantHandler) ListVariants(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+		return
+	}
+	product, err := h.Queries.GetProductByID(c, int32(productID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "productId is not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	variants, err := h.Queries.ListProductVariants(c, product.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	response, err := toVariantResponses(c, h.Queries, variants, product.Price, product.FileID)
+	if err != nil {
+		utils.Logger.Error().Err(err).Msg("Failed to build product variants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /v1/product/:productId/variants
+func (h *VariantHandler) CreateVariant(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	product, ok := authorizeProduct(c, h.Queries, userID, c.Param("productId"))
+	if !ok {
+		return
+	}
+
+	params, ok := h.bindVariant(c)
+	if !ok {
+		return
+	}
+
+	// SKUs are unique per seller, products stay keyed by the store owner
+	variant, err := h.Queries.CreateProductVariant(c, repository.CreateProductVariantParams{
+		ProductID: product.ProductID,
+		UserID:    product.UserID.Int32,
+		Sku:       params.Sku,
+		Options:   params.Options,
+		Qty:       params.Qty,
+		Price:     params.Price,
+		FileID:    params.FileID,
+	})
+	if err != nil {
+		writeVariantError(c, err)
+		return
+	}
+
+	response, err := toVariantResponses(c, h.Queries, []repository.ProductVariant{variant}, product.Price, product.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response[0])
+}
+
+// PUT /v1/product/:productId/variants/:variantId
+func (h *VariantHandler) UpdateVariant(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	product, ok := authorizeProduct(c, h.Queries, userID, c.Param("productId"))
+	if !ok {
+		return
+	}
+	variantID, err := strconv.Atoi(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "variantId is not found"})
+		return
+	}
+
+	params, ok := h.bindVariant(c)
+	if !ok {
+		return
+	}
+	params.ID = int32(variantID)
+	params.ProductID = product.ProductID
+
+	variant, err := h.Queries.UpdateProductVariant(c, params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "variantId is not found"})
+			return
+		}
+		writeVariantError(c, err)
+		return
+	}
+
+	response, err := toVariantResponses(c, h.Queries, []repository.ProductVariant{variant}, product.Price, product.FileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response[0])
+}
+
+// DELETE /v1/product/:productId/variants/:variantId
+func (h *VariantHandler) DeleteVariant(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	product, ok := authorizeProduct(c, h.Queries, userID, c.Param("productId"))
+	if !ok {
+		return
+	}
+	variantID, err := strconv.Atoi(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "variantId is not found"})
+		return
+	}
+
+	deleted, err := h.Queries.DeleteProductVariant(c, repository.DeleteProductVariantParams{
+		ID:        int32(variantID),
+		ProductID: product.ProductID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "variantId is not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
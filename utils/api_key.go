@@ -0,0 +1,26 @@
+package utils
+
+import "slices"
+
+// Scopes an API key can be granted
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeOrdersRead    = "orders:read"
+)
+
+// APIKeyPrefix marks our keys so they are easy to recognise, e.g. by secret scanners
+const APIKeyPrefix = "tlk_"
+
+var apiKeyScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeOrdersRead}
+
+// GenerateAPIKey returns a new key and the prefix shown to identify it later
+func GenerateAPIKey() (string, string) {
+	key := APIKeyPrefix + GenerateToken()
+	return key, key[:len(APIKeyPrefix)+8]
+}
+
+// ValidScope reports whether scope is one of the known scopes
+func ValidScope(scope string) bool {
+	return slices.Contains(apiKeyScopes, scope)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor packs a pagination position into an opaque URL safe string.
+// Cursors are not signed, a tampered one can only move the caller to another position.
+func EncodeCursor(position any) (string, error) {
+	payload, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// DecodeCursor unpacks a cursor made by EncodeCursor into position
+func DecodeCursor(cursor string, position any) error {
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(payload, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
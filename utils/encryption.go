@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"tutuplapak-go/config"
+)
+
+// Encrypted columns, the name is bound to the ciphertext and the blind index
+// so a value copied into another column does not decrypt or match there
+const (
+	FieldBankAccountNumber   = "bank_accounts.bank_account_number"
+	FieldSenderContactDetail = "purchases.sender_contact_detail"
+)
+
+// Ciphertexts look like "enc:v1:<kid>:<wrapped data key>:<sealed value>"
+const encryptedPrefix = "enc:v1:"
+
+var ErrUnknownDataKey = errors.New("value is encrypted with an unknown key")
+
+// DataKeyRing holds the key encryption keys. Every value is sealed with its own random
+// data key, which is stored next to it wrapped by the active key encryption key.
+type DataKeyRing struct {
+	activeID   string
+	keys       map[string]cipher.AEAD
+	blindIndex []byte
+}
+
+var DataKeys *DataKeyRing
+
+// InitDataKeys loads the configured encryption keys. There is no ephemeral fallback,
+// values encrypted with a key that is lost can never be read again.
+func InitDataKeys(cfg config.EncryptionConfig) error {
+	if len(cfg.Keys) == 0 {
+		return errors.New("ENCRYPTION_KEYS is not set")
+	}
+	if cfg.BlindIndexKeyPath == "" {
+		return errors.New("BLIND_INDEX_KEY_PATH is not set")
+	}
+
+	ring := &DataKeyRing{keys: make(map[string]cipher.AEAD)}
+	for _, keyCfg := range cfg.Keys {
+		if strings.Contains(keyCfg.ID, ":") {
+			return fmt.Errorf("data key %q: the key id must not contain a colon", keyCfg.ID)
+		}
+		if _, exists := ring.keys[keyCfg.ID]; exists {
+			return fmt.Errorf("data key %q is configured twice", keyCfg.ID)
+		}
+		key, err := readKeyFile(keyCfg.KeyPath)
+		if err != nil {
+			return fmt.Errorf("data key %q: %w", keyCfg.ID, err)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return fmt.Errorf("data key %q: %w", keyCfg.ID, err)
+		}
+		ring.keys[keyCfg.ID] = aead
+	}
+
+	ring.activeID = cfg.ActiveKeyID
+	if ring.activeID == "" {
+		ring.activeID = cfg.Keys[0].ID
+	}
+	if _, ok := ring.keys[ring.activeID]; !ok {
+		return fmt.Errorf("active data key %q is not configured", ring.activeID)
+	}
+
+	blindIndex, err := readKeyFile(cfg.BlindIndexKeyPath)
+	if err != nil {
+		return fmt.Errorf("blind index key: %w", err)
+	}
+	ring.blindIndex = blindIndex
+
+	DataKeys = ring
+	return nil
+}
+
+// readKeyFile reads a base64 encoded 256 bit key
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.New("key file is not base64 encoded")
+	}
+	if len(key) != 32 {
+		return nil, errors.New("key must be 32 bytes")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce and returns nonce and ciphertext together
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, plaintext, additionalData)
+}
+
+func unseal(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Encrypt seals value for the given field with the active key
+func (kr *DataKeyRing) Encrypt(field, value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	valueAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped := seal(kr.keys[kr.activeID], dataKey, []byte(kr.activeID+":"+field))
+	sealed := seal(valueAEAD, []byte(value), []byte(field))
+	return encryptedPrefix + kr.activeID + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value of the given field. Values stored before encryption was
+// introduced are returned unchanged until the re-encryption command has run.
+func (kr *DataKeyRing) Decrypt(field, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	keyAEAD, ok := kr.keys[parts[0]]
+	if !ok {
+		return "", ErrUnknownDataKey
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	dataKey, err := unseal(keyAEAD, wrapped, []byte(parts[0]+":"+field))
+	if err != nil {
+		return "", err
+	}
+	valueAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := unseal(valueAEAD, sealed, []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether value is plaintext or sealed with a retired key
+func (kr *DataKeyRing) NeedsReencryption(value string) bool {
+	return !strings.HasPrefix(value, encryptedPrefix+kr.activeID+":")
+}
+
+// BlindIndex returns a keyed hash of value for equality lookups on an encrypted field.
+// Callers normalize the value first, e.g. lowercase emails, so equal values match.
+func (kr *DataKeyRing) BlindIndex(field, value string) string {
+	mac := hmac.New(sha256.New, kr.blindIndex)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SenderContactIndex is the blind index of a purchase's sender contact
+func (kr *DataKeyRing) SenderContactIndex(contactType, detail string) string {
+	return kr.BlindIndex(FieldSenderContactDetail, NormalizeContactDetail(contactType, detail))
+}
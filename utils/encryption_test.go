@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tutuplapak-go/config"
+)
+
+func writeTestKey(t *testing.T, name string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	rand.Read(key)
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDataKeyRingRotation(t *testing.T) {
+	oldKey := config.DataKeyConfig{ID: "2025-04", KeyPath: writeTestKey(t, "old.key")}
+	newKey := config.DataKeyConfig{ID: "2025-10", KeyPath: writeTestKey(t, "new.key")}
+	indexKey := writeTestKey(t, "index.key")
+
+	if err := InitDataKeys(config.EncryptionConfig{Keys: []config.DataKeyConfig{oldKey}, BlindIndexKeyPath: indexKey}); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := DataKeys.Encrypt(FieldBankAccountNumber, "1234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "1234567890") || !strings.HasPrefix(sealed, "enc:v1:2025-04:") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	oldIndex := DataKeys.BlindIndex(FieldBankAccountNumber, "1234567890")
+
+	// The new key is active, values sealed with the retired one still open
+	err = InitDataKeys(config.EncryptionConfig{
+		ActiveKeyID:       newKey.ID,
+		Keys:              []config.DataKeyConfig{newKey, oldKey},
+		BlindIndexKeyPath: indexKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !DataKeys.NeedsReencryption(sealed) {
+		t.Error("value sealed with the retired key does not need re-encryption")
+	}
+	if got, err := DataKeys.Decrypt(FieldBankAccountNumber, sealed); err != nil || got != "1234567890" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+	resealed, err := DataKeys.Encrypt(FieldBankAccountNumber, "1234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DataKeys.NeedsReencryption(resealed) {
+		t.Error("value sealed with the active key needs re-encryption")
+	}
+	if DataKeys.BlindIndex(FieldBankAccountNumber, "1234567890") != oldIndex {
+		t.Error("blind index changed with the data key")
+	}
+
+	// Without the retired key its values can no longer be read
+	err = InitDataKeys(config.EncryptionConfig{Keys: []config.DataKeyConfig{newKey}, BlindIndexKeyPath: indexKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DataKeys.Decrypt(FieldBankAccountNumber, sealed); err != ErrUnknownDataKey {
+		t.Errorf("Decrypt with a removed key: err = %v", err)
+	}
+}
+
+func TestDataKeyRingRejectsTampering(t *testing.T) {
+	err := InitDataKeys(config.EncryptionConfig{
+		Keys:              []config.DataKeyConfig{{ID: "k1", KeyPath: writeTestKey(t, "k1.key")}},
+		BlindIndexKeyPath: writeTestKey(t, "index.key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := DataKeys.Encrypt(FieldSenderContactDetail, "buyer@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DataKeys.Decrypt(FieldBankAccountNumber, sealed); err == nil {
+		t.Error("value decrypted as another field")
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := DataKeys.Decrypt(FieldSenderContactDetail, tampered); err == nil {
+		t.Error("tampered value decrypted")
+	}
+
+	// Rows written before encryption are read as they are
+	if got, err := DataKeys.Decrypt(FieldSenderContactDetail, "+6281234567890"); err != nil || got != "+6281234567890" {
+		t.Errorf("Decrypt of plaintext = %q, %v", got, err)
+	}
+
+	if DataKeys.SenderContactIndex("email", " Buyer@Example.com") != DataKeys.SenderContactIndex("email", "buyer@example.com") {
+		t.Error("email blind index is case sensitive")
+	}
+	if DataKeys.BlindIndex(FieldSenderContactDetail, "x") == DataKeys.BlindIndex(FieldBankAccountNumber, "x") {
+		t.Error("blind indexes of different fields match")
+	}
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"tutuplapak-go/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one entry of the key ring. Keys without a private part can only verify.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyRing holds every key that is accepted for verification and the one used for signing
+type KeyRing struct {
+	Issuer string
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// JWK is the public representation of a key as published in the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+var JWTKeys *KeyRing
+
+// InitJWTKeys loads the configured signing keys. Without configuration an ephemeral
+// Ed25519 key is generated, which is only suitable for local development.
+func InitJWTKeys(cfg config.JWTConfig) error {
+	ring := &KeyRing{Issuer: cfg.Issuer, keys: make(map[string]*SigningKey)}
+
+	if len(cfg.Keys) == 0 {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		id := make([]byte, 4)
+		rand.Read(id)
+		key := &SigningKey{
+			ID:        "ephemeral-" + hex.EncodeToString(id),
+			Method:    jwt.SigningMethodEdDSA,
+			signKey:   priv,
+			verifyKey: priv.Public(),
+		}
+		ring.keys[key.ID] = key
+		ring.active = key
+		Logger.Warn().Str("kid", key.ID).Msg("JWT_KEYS is not set, using an ephemeral signing key, tokens will not survive a restart")
+		JWTKeys = ring
+		return nil
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadSigningKey(keyCfg)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", keyCfg.ID, err)
+		}
+		if _, exists := ring.keys[key.ID]; exists {
+			return fmt.Errorf("jwt key %q is configured twice", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	activeID := cfg.ActiveKeyID
+	if activeID == "" {
+		activeID = cfg.Keys[0].ID
+	}
+	active, ok := ring.keys[activeID]
+	if !ok {
+		return fmt.Errorf("active jwt key %q is not configured", activeID)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("active jwt key %q has no private key", activeID)
+	}
+	ring.active = active
+
+	JWTKeys = ring
+	return nil
+}
+
+func loadSigningKey(cfg config.JWTKeyConfig) (*SigningKey, error) {
+	data, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: cfg.ID}
+	switch cfg.Algorithm {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.signKey = secret
+		key.verifyKey = secret
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if priv, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.signKey = priv
+			key.verifyKey = &priv.PublicKey
+		} else if pub, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			key.verifyKey = pub
+		} else {
+			return nil, errors.New("invalid RSA PEM key")
+		}
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if priv, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			key.signKey = priv
+			key.verifyKey = priv.(ed25519.PrivateKey).Public()
+		} else if pub, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+			key.verifyKey = pub
+		} else {
+			return nil, errors.New("invalid Ed25519 PEM key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (kr *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.active.Method, claims)
+	token.Header["kid"] = kr.active.ID
+	return token.SignedString(kr.active.signKey)
+}
+
+// Keyfunc resolves the verification key from the kid header and pins its algorithm
+func (kr *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// Algorithms lists the algorithms of every configured key
+func (kr *KeyRing) Algorithms() []string {
+	var algs []string
+	for _, key := range kr.keys {
+		if alg := key.Method.Alg(); !slices.Contains(algs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// JWKS returns the public keys for the JWKS endpoint, HMAC secrets are never published
+func (kr *KeyRing) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return jwks
+}
+
+// ParseJWTToken verifies a token against the key ring and fills claims
+func ParseJWTToken(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, JWTKeys.Keyfunc,
+		jwt.WithValidMethods(JWTKeys.Algorithms()),
+		jwt.WithIssuer(JWTKeys.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"time"
+)
+
+const (
+	// Failures older than the window no longer count
+	LoginFailureWindow = 15 * time.Minute
+
+	// Failures allowed before every further attempt has to wait
+	LoginAccountFreeAttempts = 3
+	LoginIPFreeAttempts      = 20
+
+	// The first lock past the free attempts, it doubles with every further attempt up to LoginLockMax
+	LoginLockBase = 2 * time.Second
+	LoginLockMax  = 15 * time.Minute
+)
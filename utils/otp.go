@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	OTPTTL            = 5 * time.Minute
+	OTPMaxAttempts    = 5
+	OTPResendInterval = time.Minute
+	OTPMaxPerHour     = 5
+)
+
+// OTP purposes, a code issued for one purpose can not be used for another
+const (
+	OTPPurposeVerifyPhone   = "verify_phone"
+	OTPPurposeResetPassword = "reset_password"
+)
+
+// GenerateOTPCode returns a random 6 digit numeric code
+func GenerateOTPCode() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1000000))
+	return fmt.Sprintf("%06d", n.Int64())
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"tutuplapak-go/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords and verifies them. Verify also reports whether
+// a matching hash should be replaced because it uses an older algorithm or cost.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (ok, needsRehash bool, err error)
+}
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// Argon2idHasher stores PHC strings, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+// Hashes from before argon2id are bcrypt and are still accepted.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Passwords is the hasher for user passwords, InitPasswordHasher applies the configured cost
+var Passwords PasswordHasher = NewArgon2idHasher(config.PasswordConfig{Memory: 64 * 1024, Iterations: 3, Parallelism: 2})
+
+// NewArgon2idHasher expects a cost InitPasswordHasher accepts
+func NewArgon2idHasher(cfg config.PasswordConfig) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      uint32(cfg.Memory),
+		Iterations:  uint32(cfg.Iterations),
+		Parallelism: uint8(cfg.Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// InitPasswordHasher applies the configured cost. It returns an error for a cost argon2id
+// can not use, which would otherwise panic or silently wrap on the first hash.
+func InitPasswordHasher(cfg config.PasswordConfig) error {
+	switch {
+	case cfg.Iterations < 1 || cfg.Iterations > math.MaxUint32:
+		return fmt.Errorf("PASSWORD_ARGON2_ITERATIONS must be between 1 and %d, got %d", uint32(math.MaxUint32), cfg.Iterations)
+	case cfg.Parallelism < 1 || cfg.Parallelism > math.MaxUint8:
+		return fmt.Errorf("PASSWORD_ARGON2_PARALLELISM must be between 1 and %d, got %d", math.MaxUint8, cfg.Parallelism)
+	case cfg.Memory < 8*cfg.Parallelism || cfg.Memory > math.MaxUint32:
+		return fmt.Errorf("PASSWORD_ARGON2_MEMORY must be between 8 KiB per lane (%d) and %d, got %d", 8*cfg.Parallelism, uint32(math.MaxUint32), cfg.Memory)
+	}
+	Passwords = NewArgon2idHasher(cfg)
+	return nil
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(hash, password string) (bool, bool, error) {
+	if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return err == nil, true, err
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return false, false, ErrUnknownPasswordHash
+	}
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownPasswordHash
+	}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false, ErrUnknownPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrUnknownPasswordHash
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+	needsRehash := memory != h.Memory || iterations != h.Iterations || parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength || uint32(len(key)) != h.KeyLength
+	return true, needsRehash, nil
+}
+
+// common_passwords.txt lists passwords seen in breaches and common enough to be
+// guessed, one per line in lowercase
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+)
+
+// IsCommonPassword reports whether password is on the shipped list, ignoring case
+func IsCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(func() {
+		commonPasswords = make(map[string]struct{})
+		scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				commonPasswords[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+	_, found := commonPasswords[strings.ToLower(password)]
+	return found
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"tutuplapak-go/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Low cost keeps the test fast, the format does not depend on it
+var testPasswordConfig = config.PasswordConfig{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher(testPasswordConfig)
+	hash, err := hasher.Hash("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("hash = %q", hash)
+	}
+
+	if ok, needsRehash, err := hasher.Verify(hash, "correct horse battery"); !ok || needsRehash || err != nil {
+		t.Errorf("Verify = %v, %v, %v", ok, needsRehash, err)
+	}
+	if ok, _, err := hasher.Verify(hash, "wrong horse battery"); ok || err != nil {
+		t.Errorf("Verify with a wrong password = %v, %v", ok, err)
+	}
+
+	// A higher configured cost asks for the hash to be upgraded
+	stronger := NewArgon2idHasher(config.PasswordConfig{Memory: 2048, Iterations: 1, Parallelism: 1})
+	if ok, needsRehash, _ := stronger.Verify(hash, "correct horse battery"); !ok || !needsRehash {
+		t.Errorf("Verify with a higher cost = %v, needsRehash %v", ok, needsRehash)
+	}
+}
+
+func TestInitPasswordHasherRejectsInvalidCost(t *testing.T) {
+	for _, cfg := range []config.PasswordConfig{
+		{Memory: 1024, Iterations: 0, Parallelism: 1},
+		{Memory: 1024, Iterations: -1, Parallelism: 1},
+		{Memory: 1024, Iterations: 1, Parallelism: 0},
+		{Memory: 4096, Iterations: 1, Parallelism: 256}, // would wrap to 0 as uint8
+		{Memory: 15, Iterations: 1, Parallelism: 2},     // less than 8 KiB per lane
+		{Memory: 1 << 32, Iterations: 1, Parallelism: 1},
+	} {
+		if err := InitPasswordHasher(cfg); err == nil {
+			t.Errorf("InitPasswordHasher(%+v) accepted an invalid cost", cfg)
+		}
+	}
+}
+
+func TestArgon2idHasherAcceptsBcrypt(t *testing.T) {
+	hasher := NewArgon2idHasher(testPasswordConfig)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, needsRehash, err := hasher.Verify(string(legacy), "correct horse battery"); !ok || !needsRehash || err != nil {
+		t.Errorf("Verify bcrypt = %v, %v, %v", ok, needsRehash, err)
+	}
+	if ok, _, err := hasher.Verify(string(legacy), "wrong horse battery"); ok || err != nil {
+		t.Errorf("Verify bcrypt with a wrong password = %v, %v", ok, err)
+	}
+	if _, _, err := hasher.Verify("plaintext", "plaintext"); err != ErrUnknownPasswordHash {
+		t.Errorf("Verify of an unknown format: err = %v", err)
+	}
+}
+
+func TestIsCommonPassword(t *testing.T) {
+	for _, password := range []string{"password123", "Password123", "QWERTY123", "bismillah"} {
+		if !IsCommonPassword(password) {
+			t.Errorf("IsCommonPassword(%q) = false", password)
+		}
+	}
+	if IsCommonPassword("vT9#kq2!rmB7") {
+		t.Error("random password reported as common")
+	}
+}
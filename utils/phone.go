@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// PhoneRegion holds the numbering rules of one country. NationalPattern matches the
+// national significant number, the digits after the calling code without trunk prefix.
+type PhoneRegion struct {
+	Code            string
+	CallingCode     string
+	TrunkPrefix     string
+	NationalPattern *regexp.Regexp
+}
+
+// PhoneNumber is a parsed phone number. Region is empty for calling codes without
+// metadata, those numbers are only checked against the general E.164 length.
+type PhoneNumber struct {
+	E164           string
+	Region         string
+	CallingCode    string
+	NationalNumber string
+}
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// Numbers without a calling code are read as numbers of this region
+const DefaultPhoneRegion = "ID"
+
+var phoneRegions = []PhoneRegion{
+	{
+		// Mobile numbers start with 8, landlines with a 2 to 4 digit area code
+		Code:            "ID",
+		CallingCode:     "62",
+		TrunkPrefix:     "0",
+		NationalPattern: regexp.MustCompile(`^(8\d{8,11}|[2-79]\d{7,10})$`),
+	},
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+	phoneDigits     = regexp.MustCompile(`^[1-9]\d*$`)
+)
+
+func phoneRegionByCode(code string) (PhoneRegion, bool) {
+	for _, region := range phoneRegions {
+		if region.Code == code {
+			return region, true
+		}
+	}
+	return PhoneRegion{}, false
+}
+
+// ParsePhone reads a phone number as people type it, e.g. "+62 812-3456-7890",
+// "+620812..." or "0812..." for the default region, and returns it in E.164.
+func ParsePhone(input string) (PhoneNumber, error) {
+	number := phoneSeparators.Replace(strings.TrimSpace(input))
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+
+	if !strings.HasPrefix(number, "+") {
+		region, _ := phoneRegionByCode(DefaultPhoneRegion)
+		if region.TrunkPrefix == "" || !strings.HasPrefix(number, region.TrunkPrefix) {
+			return PhoneNumber{}, ErrInvalidPhone
+		}
+		return parseNationalNumber(region, strings.TrimPrefix(number, region.TrunkPrefix))
+	}
+
+	digits := number[1:]
+	if !phoneDigits.MatchString(digits) {
+		return PhoneNumber{}, ErrInvalidPhone
+	}
+	for _, region := range phoneRegions {
+		if strings.HasPrefix(digits, region.CallingCode) {
+			national := strings.TrimPrefix(digits, region.CallingCode)
+			// The trunk prefix is often kept after the calling code, e.g. +62 0812...
+			if region.TrunkPrefix != "" {
+				national = strings.TrimPrefix(national, region.TrunkPrefix)
+			}
+			return parseNationalNumber(region, national)
+		}
+	}
+
+	// E.164 allows up to 15 digits, shorter than 8 is not a dialable number anywhere we sell
+	if len(digits) < 8 || len(digits) > 15 {
+		return PhoneNumber{}, ErrInvalidPhone
+	}
+	return PhoneNumber{E164: "+" + digits}, nil
+}
+
+func parseNationalNumber(region PhoneRegion, national string) (PhoneNumber, error) {
+	if !region.NationalPattern.MatchString(national) {
+		return PhoneNumber{}, ErrInvalidPhone
+	}
+	return PhoneNumber{
+		E164:           "+" + region.CallingCode + national,
+		Region:         region.Code,
+		CallingCode:    region.CallingCode,
+		NationalNumber: national,
+	}, nil
+}
+
+// NormalizePhone returns the E.164 form of phone, phones are stored and looked up in it
+func NormalizePhone(phone string) (string, bool) {
+	parsed, err := ParsePhone(phone)
+	if err != nil {
+		return "", false
+	}
+	return parsed.E164, true
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "testing"
+
+func TestParsePhone(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"+6281234567890", "+6281234567890"},
+		{"+62 812-3456-7890", "+6281234567890"},
+		{"+62 (812) 3456.7890", "+6281234567890"},
+		{"+62081234567890", "+6281234567890"},
+		{"081234567890", "+6281234567890"},
+		{"006281234567890", "+6281234567890"},
+		{"+62 21 1234 5678", "+622112345678"},
+		{"+14155552671", "+14155552671"},
+	}
+	for _, tt := range tests {
+		parsed, err := ParsePhone(tt.input)
+		if err != nil {
+			t.Errorf("ParsePhone(%q): %v", tt.input, err)
+			continue
+		}
+		if parsed.E164 != tt.want {
+			t.Errorf("ParsePhone(%q) = %q, want %q", tt.input, parsed.E164, tt.want)
+		}
+	}
+
+	parsed, _ := ParsePhone("0812-3456-7890")
+	if parsed.Region != "ID" || parsed.CallingCode != "62" || parsed.NationalNumber != "81234567890" {
+		t.Errorf("ParsePhone metadata = %+v", parsed)
+	}
+}
+
+func TestParsePhoneRejectsInvalidNumbers(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"81234567890",      // no calling code and no trunk prefix
+		"+62812345",        // too short for a mobile number
+		"+628123456789012", // too long for a mobile number
+		"+62112345678",     // Indonesian numbers do not start with 1
+		"+6281234abc890",
+		"+0123456789",
+		"+1234567",
+		"+1234567890123456",
+	} {
+		if _, err := ParsePhone(input); err == nil {
+			t.Errorf("ParsePhone(%q) succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import "slices"
+
+// Roles stored on users and carried in access tokens
+const (
+	RoleBuyer  = "buyer"
+	RoleSeller = "seller"
+	RoleAdmin  = "admin"
+)
+
+// Permissions are granted to roles, handlers check permissions rather than role names
+// where a capability may be shared by several roles
+const (
+	PermissionManageUsers      = "users:manage"
+	PermissionManageLockouts   = "lockouts:manage"
+	PermissionManageCategories = "categories:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleBuyer:  {},
+	RoleSeller: {},
+	RoleAdmin: {
+		PermissionManageUsers,
+		PermissionManageLockouts,
+		PermissionManageCategories,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the role grants the permission, unknown roles grant nothing
+func HasPermission(role string, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// Store permissions are granted per store to staff members, the store owner holds all of them
+const (
+	StorePermissionManageProducts = "products:manage"
+	StorePermissionViewOrders     = "orders:view"
+	StorePermissionConfirmPayment = "payments:confirm"
+)
+
+// StorePermissions lists every permission an owner can grant to staff
+var StorePermissions = []string{
+	StorePermissionManageProducts,
+	StorePermissionViewOrders,
+	StorePermissionConfirmPayment,
+}
+
+// ValidStorePermission reports whether permission is one of the store permissions
+func ValidStorePermission(permission string) bool {
+	return slices.Contains(StorePermissions, permission)
+}
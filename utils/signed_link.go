@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"tutuplapak-go/config"
+)
+
+// Link purposes, a token signed for one purpose is rejected for any other.
+// Login2FA tokens are never mailed, they carry a password login over to the TOTP step.
+const (
+	LinkPurposeVerifyEmail = "verify_email"
+	LinkPurposeChangeEmail = "change_email"
+	LinkPurposeLogin2FA    = "login_2fa"
+)
+
+var ErrInvalidLinkToken = errors.New("invalid or expired link")
+
+// LinkClaims is the payload of a signed link token
+type LinkClaims struct {
+	// Set on tokens that can only be redeemed once
+	ID        string `json:"jti,omitempty"`
+	UserID    int32  `json:"uid"`
+	Email     string `json:"email,omitempty"`
+	Purpose   string `json:"purpose"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	linkSecret  []byte
+	linkBaseURL string
+)
+
+// InitLinkSigner sets the HMAC secret and base URL for signed links. Without a secret
+// a random one is generated, links then stop working after a restart.
+func InitLinkSigner(cfg config.AppConfig) {
+	linkBaseURL = cfg.BaseURL
+	if cfg.LinkSecret == "" {
+		linkSecret = make([]byte, 32)
+		rand.Read(linkSecret)
+		Logger.Warn().Msg("APP_LINK_SECRET is not set, using an ephemeral secret, links will not survive a restart")
+		return
+	}
+	linkSecret = []byte(cfg.LinkSecret)
+}
+
+// LinkURL builds an absolute link to path carrying the token as query parameter
+func LinkURL(path string, token string) string {
+	return linkBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// SignLinkToken returns a URL safe token carrying the claims, valid for ttl
+func SignLinkToken(claims LinkClaims, ttl time.Duration) (string, error) {
+	claims.ExpiresAt = time.Now().Add(ttl).Unix()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signLink(encoded)), nil
+}
+
+// VerifyLinkToken checks the signature, expiry and purpose of a token
+func VerifyLinkToken(token string, purpose string) (LinkClaims, error) {
+	var claims LinkClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, ErrInvalidLinkToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, signLink(encoded)) {
+		return claims, ErrInvalidLinkToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, ErrInvalidLinkToken
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, ErrInvalidLinkToken
+	}
+	if claims.Purpose != purpose || time.Now().Unix() > claims.ExpiresAt {
+		return claims, ErrInvalidLinkToken
+	}
+	return claims, nil
+}
+
+func signLink(encoded string) []byte {
+	mac := hmac.New(sha256.New, linkSecret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -1,28 +1,44 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
-	"sync"
+	"errors"
 	"time"
+	"unicode/utf8"
+
+	"tutuplapak-go/repository"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Simple in-memory token store (use Redis or DB in production)
+const (
+	AccessTokenTTL  = 2 * time.Hour
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	// How often last_seen_at is written for an active session
+	sessionTouchInterval = time.Minute
+)
+
+// Postgres backed session store, shared by every replica and kept across restarts
 type TokenStore struct {
-	tokens map[string]TokenData
-	mutex  sync.RWMutex
+	queries *repository.Queries
 }
 
-type TokenData struct {
-	UserID    int32
-	CreatedAt time.Time
-	ExpiresAt time.Time
+// SessionInfo describes the device a session was started from
+type SessionInfo struct {
+	UserAgent string
+	IPAddress string
 }
 
-var GlobalTokenStore = &TokenStore{
-	tokens: make(map[string]TokenData),
+var GlobalTokenStore *TokenStore
+
+// InitTokenStore must be called once the database is available
+func InitTokenStore(queries *repository.Queries) {
+	GlobalTokenStore = &TokenStore{queries: queries}
 }
 
 func GenerateToken() string {
@@ -31,47 +47,69 @@ func GenerateToken() string {
 	return hex.EncodeToString(bytes)
 }
 
-func (ts *TokenStore) StoreToken(token string, userID int32) {
-	ts.mutex.Lock()
-	defer ts.mutex.Unlock()
-	ts.tokens[token] = TokenData{
+// HashToken returns the hex encoded SHA-256 of an opaque token, used to store tokens at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (ts *TokenStore) StoreToken(ctx context.Context, token string, userID int32, info SessionInfo) error {
+	_, err := ts.queries.CreateSession(ctx, repository.CreateSessionParams{
 		UserID:    userID,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour), // Token expires in 24 hours
-	}
+		TokenHash: HashToken(token),
+		UserAgent: sql.NullString{String: truncate(info.UserAgent, 255), Valid: info.UserAgent != ""},
+		IpAddress: sql.NullString{String: truncate(info.IPAddress, 45), Valid: info.IPAddress != ""},
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	})
+	return err
 }
 
-func (ts *TokenStore) GetUserID(token string) (int32, bool) {
-	ts.mutex.RLock()
-	defer ts.mutex.RUnlock()
-	tokenData, exists := ts.tokens[token]
-	if !exists {
+func (ts *TokenStore) GetUserID(ctx context.Context, token string) (int32, bool) {
+	session, err := ts.queries.GetActiveSessionByTokenHash(ctx, HashToken(token))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			Logger.Error().Err(err).Msg("Failed to get session")
+		}
 		return 0, false
 	}
-	// Check if token is expired
-	if time.Now().After(tokenData.ExpiresAt) {
-		// Clean up expired token
-		delete(ts.tokens, token)
-		return 0, false
+	// Throttle writes, last seen only needs to be accurate to the minute
+	if !session.LastSeenAt.Valid || time.Since(session.LastSeenAt.Time) > sessionTouchInterval {
+		if err := ts.queries.TouchSession(ctx, session.ID); err != nil {
+			Logger.Error().Err(err).Msg("Failed to touch session")
+		}
 	}
-	return tokenData.UserID, true
+	return session.UserID, true
+}
+
+// ExtendToken slides the session expiry, called whenever its refresh token rotates
+func (ts *TokenStore) ExtendToken(ctx context.Context, token string) error {
+	return ts.queries.ExtendSession(ctx, repository.ExtendSessionParams{
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	})
+}
+
+func (ts *TokenStore) DeleteToken(ctx context.Context, token string) error {
+	return ts.queries.RevokeSessionByTokenHash(ctx, HashToken(token))
+}
+
+// DeleteUserTokens ends every session of a user
+func (ts *TokenStore) DeleteUserTokens(ctx context.Context, userID int32) error {
+	return ts.queries.RevokeUserSessions(ctx, userID)
 }
 
-func (ts *TokenStore) DeleteToken(token string) {
-	ts.mutex.Lock()
-	defer ts.mutex.Unlock()
-	delete(ts.tokens, token)
+// DeleteOtherUserTokens ends every session of a user except the given one
+func (ts *TokenStore) DeleteOtherUserTokens(ctx context.Context, userID int32, keepToken string) error {
+	return ts.queries.RevokeOtherUserSessions(ctx, repository.RevokeOtherUserSessionsParams{
+		UserID:    userID,
+		TokenHash: HashToken(keepToken),
+	})
 }
 
-// Cleanup expired tokens periodically
+// Cleanup expired sessions periodically
 func (ts *TokenStore) CleanupExpiredTokens() {
-	ts.mutex.Lock()
-	defer ts.mutex.Unlock()
-	now := time.Now()
-	for token, tokenData := range ts.tokens {
-		if now.After(tokenData.ExpiresAt) {
-			delete(ts.tokens, token)
-		}
+	if err := ts.queries.DeleteExpiredSessions(context.Background()); err != nil {
+		Logger.Error().Err(err).Msg("Failed to delete expired sessions")
 	}
 }
 
@@ -85,13 +123,31 @@ func (ts *TokenStore) StartCleanupRoutine() {
 	}()
 }
 
-func GenerateJWTToken(userID uint) (string, error) {
-	tokenString := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// truncate cuts value to at most max bytes without splitting a multi-byte rune,
+// Postgres rejects invalid UTF-8
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	for max > 0 && !utf8.RuneStart(value[max]) {
+		max--
+	}
+	return value[:max]
+}
+
+// GenerateJWTToken issues an access token, jti identifies it so it can be revoked before it expires
+// and sessionID ties it to the session it was issued for
+func GenerateJWTToken(userID uint, role string, jti string, sessionID string) (string, error) {
+	now := time.Now()
+	token, err := JWTKeys.Sign(jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 2).Unix(),
+		"role":    role,
+		"sid":     sessionID,
+		"jti":     jti,
+		"iss":     JWTKeys.Issuer,
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenTTL).Unix(),
 	})
-
-	token, err := tokenString.SignedString([]byte("secret"))
 	if err != nil {
 		return "", err
 	}
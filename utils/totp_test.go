@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to our 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		code, err := TOTPCode(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := MatchTOTP(rfc6238Secret, code, now)
+		if !ok || step != current+offset {
+			t.Errorf("code of step %+d = %d, %v, want %d, true", offset, step, ok, current+offset)
+		}
+	}
+
+	// Codes outside the skew window are refused
+	for _, offset := range []int64{-totpSkew - 1, totpSkew + 1} {
+		code, err := TOTPCode(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := MatchTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("code of step %+d was accepted", offset)
+		}
+	}
+
+	for _, code := range []string{"", "12345", "1234567"} {
+		if _, ok := MatchTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("MatchTOTP accepted %q", code)
+		}
+	}
+}
@@ -2,16 +2,55 @@ package utils
 
 import (
 	"regexp"
+	"strings"
 )
 
-func ValidatePhone(phone string) bool {
-	// Phone should begin with + and international calling number
-	phoneRegex := regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
-	return phoneRegex.MatchString(phone)
-}
-
 func ValidateEmail(email string) bool {
 	// Simple email regex pattern
 	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	return emailRegex.MatchString(email)
 }
+
+// NormalizeBankAccountNumber drops the spaces, dashes and dots people type into account numbers
+func NormalizeBankAccountNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "").Replace(number)
+}
+
+// ValidateBankAccountNumber checks a normalized account number against a bank's rules
+func ValidateBankAccountNumber(number string, minLength, maxLength int32, pattern string) bool {
+	if len(number) < int(minLength) || len(number) > int(maxLength) {
+		return false
+	}
+	matched, err := regexp.MatchString(pattern, number)
+	return err == nil && matched
+}
+
+// NormalizeContactDetail returns the form contact details are compared in,
+// emails are case-insensitive and phones are compared in E.164
+func NormalizeContactDetail(contactType, detail string) string {
+	detail = strings.TrimSpace(detail)
+	switch contactType {
+	case "email":
+		return strings.ToLower(detail)
+	case "phone":
+		if phone, ok := NormalizePhone(detail); ok {
+			return phone
+		}
+	}
+	return detail
+}
+
+var (
+	slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+	slugPattern    = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Slugify turns a name into a lowercase, dash separated slug, empty when nothing is left
+func Slugify(name string) string {
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// ValidSlug reports whether slug is already in the form Slugify produces
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}